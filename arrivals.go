@@ -0,0 +1,59 @@
+package routing
+
+import "time"
+
+// Arrivals contains the earliest arrivals at all stops of a timetable when starting
+// at a source at a certain time. It is the result of QueryAll.
+type Arrivals struct {
+	timetable *Timetable
+	labels    []label
+}
+
+// QueryAll computes the earliest arrivals at all stops of the timetable when starting at the source at the given time,
+// e.g. in order to draw isochrone maps. The search is the same as the search of Query, but it does not stop when a
+// certain target is reached. The errors are the same as the errors of Query, except that ErrNoConnection is never returned.
+func (t *Timetable) QueryAll(source *Stop, start time.Time) (*Arrivals, error) {
+	s, err := t.vertex(source, "source")
+	if err != nil {
+		return nil, err
+	}
+	if t.invalid != nil {
+		return nil, t.invalid
+	}
+	return &Arrivals{timetable: t, labels: t.graph.earliestArrivals(s, nil, start, start.Add(t.horizon))}, nil
+}
+
+// Arrival returns the earliest arrival at the stop. The arrival at the source is the start time.
+// If the stop cannot be reached or is not part of the timetable, then false is returned.
+func (a *Arrivals) Arrival(stop *Stop) (time.Time, bool) {
+	if !a.timetable.contains(stop) {
+		return time.Time{}, false
+	}
+	weight := a.labels[a.timetable.stops[stop.Id].id].weight
+	return weight, weight != time.Time{}
+}
+
+// Connection returns the fastest connection to the stop. If the stop is not part of the timetable,
+// then ErrUnknownStop is returned. If the stop cannot be reached or is the source, then ErrNoConnection is returned.
+func (a *Arrivals) Connection(stop *Stop) (*Connection, error) {
+	target, err := a.timetable.vertex(stop, "target")
+	if err != nil {
+		return nil, err
+	}
+	connection := createConnection(pathTo(&a.labels[target.id]))
+	if connection == nil {
+		return nil, ErrNoConnection
+	}
+	return connection, nil
+}
+
+// Reached returns the earliest arrivals at all stops which can be reached, including the source.
+func (a *Arrivals) Reached() map[*Stop]time.Time {
+	result := make(map[*Stop]time.Time)
+	for _, l := range a.labels {
+		if l.weight != (time.Time{}) {
+			result[l.vertex.data] = l.weight
+		}
+	}
+	return result
+}
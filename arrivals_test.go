@@ -0,0 +1,60 @@
+package routing
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestTimetable_QueryAll(t *testing.T) {
+	network := createTestNetwork()
+	footpath := Footpath{From: network.docksAE, To: network.docksFG, Duration: 4 * time.Minute}
+	timetable := NewTimetable(network.stops(), WithFootpaths(footpath))
+
+	t.Run("same as query", func(t *testing.T) {
+		for _, source := range network.stops() {
+			for _, start := range []string{"8:04", "10:25", "14:34"} {
+				arrivals, err := timetable.QueryAll(source, date(start))
+				require.NoError(t, err)
+				for _, target := range network.stops() {
+					expected, err := timetable.Query(source, target, date(start))
+					connection, allErr := arrivals.Connection(target)
+					require.Equal(t, err, allErr, "errors differ for %s -> %s at %s", source.Id, target.Id, start)
+					arrival, ok := arrivals.Arrival(target)
+					if err != nil {
+						assert.Equal(t, source == target, ok, "%s -> %s at %s: only the source should be reached without connection", source.Id, target.Id, start)
+						continue
+					}
+					assert.True(t, ok, "%s -> %s at %s: stop should be reached", source.Id, target.Id, start)
+					assert.Equal(t, expected.Arrival, arrival, "%s -> %s at %s: arrival is wrong", source.Id, target.Id, start)
+					assert.Equal(t, expected, connection, "%s -> %s at %s: connection is wrong", source.Id, target.Id, start)
+				}
+			}
+		}
+	})
+	t.Run("reached", func(t *testing.T) {
+		arrivals, err := timetable.QueryAll(network.northEnd, date("10:25"))
+		require.NoError(t, err)
+		reached := arrivals.Reached()
+		assert.Equal(t, 9, len(reached), "all stops except market place should be reached")
+		assert.Equal(t, date("10:25"), reached[network.northEnd], "arrival at the source is wrong")
+		assert.Equal(t, date("10:36"), reached[network.docksFG], "arrival at docks F and G is wrong")
+		_, ok := reached[network.marketPlace]
+		assert.False(t, ok, "market place should not be reached")
+	})
+	t.Run("unknown stop", func(t *testing.T) {
+		arrivals, err := timetable.QueryAll(NewStop("XY", "Unknown"), date("8:00"))
+		assert.True(t, errors.Is(err, ErrUnknownStop), "error should be ErrUnknownStop")
+		assert.Nil(t, arrivals, "no arrivals should be returned")
+
+		arrivals, err = timetable.QueryAll(network.mainStation, date("8:00"))
+		require.NoError(t, err)
+		_, ok := arrivals.Arrival(NewStop("XY", "Unknown"))
+		assert.False(t, ok, "unknown stop should not be reached")
+		connection, err := arrivals.Connection(nil)
+		assert.True(t, errors.Is(err, ErrUnknownStop), "error should be ErrUnknownStop")
+		assert.Nil(t, connection, "no connection should be returned")
+	})
+}
@@ -0,0 +1,204 @@
+package routing
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// createGridNetwork creates a synthetic network with size x size stops. Every row and every
+// column of the grid is served by a line in both directions. The vehicles of all lines run every
+// headway minutes from 5 to 23 o'clock and need two minutes between two neighbouring stops.
+func createGridNetwork(size int, headway int) [][]*Stop {
+	grid := make([][]*Stop, size)
+	for row := range grid {
+		grid[row] = make([]*Stop, size)
+		for column := range grid[row] {
+			id := fmt.Sprintf("%d-%d", row, column)
+			grid[row][column] = NewStop(id, "Stop "+id)
+		}
+	}
+	addTrips := func(id string, stops []*Stop) {
+		line := &Line{Id: id, Name: "Line " + id}
+		for minute := 5 * 60; minute < 23*60; minute += headway {
+			stopTimes := make([]StopTime, 0, len(stops))
+			for i, stop := range stops {
+				at := CreateTime((minute+2*i)/60, (minute+2*i)%60)
+				stopTimes = append(stopTimes, StopTime{Stop: stop, Arrival: at, Departure: at})
+			}
+			if _, err := NewTrip(fmt.Sprintf("%s-%d", id, minute), line, stopTimes); err != nil {
+				panic(err)
+			}
+		}
+	}
+	for i := 0; i < size; i++ {
+		row := make([]*Stop, 0, size)
+		column := make([]*Stop, 0, size)
+		for j := 0; j < size; j++ {
+			row = append(row, grid[i][j])
+			column = append(column, grid[j][i])
+		}
+		addTrips(fmt.Sprintf("R%dE", i), row)
+		addTrips(fmt.Sprintf("R%dW", i), reverseStops(row))
+		addTrips(fmt.Sprintf("C%dS", i), column)
+		addTrips(fmt.Sprintf("C%dN", i), reverseStops(column))
+	}
+	return grid
+}
+
+func reverseStops(stops []*Stop) []*Stop {
+	result := make([]*Stop, 0, len(stops))
+	for i := len(stops) - 1; i >= 0; i-- {
+		result = append(result, stops[i])
+	}
+	return result
+}
+
+func flattenGrid(grid [][]*Stop) []*Stop {
+	result := make([]*Stop, 0, len(grid)*len(grid))
+	for _, row := range grid {
+		result = append(result, row...)
+	}
+	return result
+}
+
+func BenchmarkTimetable_Query(b *testing.B) {
+	grid := createGridNetwork(20, 10)
+	timetable := NewTimetable(flattenGrid(grid))
+	source := grid[0][0]
+	target := grid[19][19]
+	start := date("8:00")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := timetable.Query(source, target, start); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTimetable_QueryNearby(b *testing.B) {
+	grid := createGridNetwork(20, 10)
+	timetable := NewTimetable(flattenGrid(grid))
+	source := grid[0][0]
+	target := grid[2][3]
+	start := date("8:00")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := timetable.Query(source, target, start); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTimetable_QueryTestNetwork(b *testing.B) {
+	network := createTestNetwork()
+	timetable := NewTimetable(network.stops())
+	start := date("9:30")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := timetable.Query(network.northEnd, network.chalet, start); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNewTimetable(b *testing.B) {
+	stops := flattenGrid(createGridNetwork(20, 10))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewTimetable(stops)
+	}
+}
+
+func BenchmarkRaptor_Query(b *testing.B) {
+	grid := createGridNetwork(20, 10)
+	timetable := NewTimetable(flattenGrid(grid))
+	raptor := NewRaptor(&timetable)
+	source := grid[0][0]
+	target := grid[19][19]
+	start := date("8:00")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := raptor.Query(source, target, start); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTimetable_QueryPareto(b *testing.B) {
+	grid := createGridNetwork(20, 10)
+	timetable := NewTimetable(flattenGrid(grid))
+	source := grid[0][0]
+	target := grid[2][3]
+	start := date("8:00")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := timetable.QueryPareto(source, target, start, 5); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkConnectionScan_Query(b *testing.B) {
+	grid := createGridNetwork(20, 10)
+	timetable := NewTimetable(flattenGrid(grid))
+	scan := NewConnectionScan(&timetable)
+	source := grid[0][0]
+	target := grid[19][19]
+	start := date("8:00")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := scan.Query(source, target, start); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkConnectionScan_QueryNearby(b *testing.B) {
+	grid := createGridNetwork(20, 10)
+	timetable := NewTimetable(flattenGrid(grid))
+	scan := NewConnectionScan(&timetable)
+	source := grid[0][0]
+	target := grid[2][3]
+	start := date("8:00")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := scan.Query(source, target, start); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkConnectionScan_QueryTestNetwork(b *testing.B) {
+	network := createTestNetwork()
+	timetable := NewTimetable(network.stops())
+	scan := NewConnectionScan(&timetable)
+	start := date("9:30")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := scan.Query(network.northEnd, network.chalet, start); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNewConnectionScan(b *testing.B) {
+	timetable := NewTimetable(flattenGrid(createGridNetwork(20, 10)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewConnectionScan(&timetable)
+	}
+}
+
+func BenchmarkTimetable_QueryMatrix(b *testing.B) {
+	grid := createGridNetwork(20, 10)
+	stops := flattenGrid(grid)
+	timetable := NewTimetable(stops)
+	departures := []time.Time{date("8:00"), date("12:00")}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := timetable.QueryMatrix(stops[:50], stops, departures); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
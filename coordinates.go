@@ -0,0 +1,86 @@
+package routing
+
+import (
+	"math"
+	"time"
+)
+
+// DefaultWalkingRadius is the maximum distance in meters which passengers walk from their origin to the first stop
+// and from the last stop to their destination if no other radius is configured.
+const DefaultWalkingRadius = 800.0
+
+// DefaultWalkingSpeed is the walking speed of passengers in meters per second if no other speed is configured.
+const DefaultWalkingSpeed = 1.2
+
+// WithWalking sets the maximum distance (in meters) and the speed (in meters per second) of walking from the origin
+// to the first stop and from the last stop to the destination in QueryCoordinates. If the option is not given,
+// then DefaultWalkingRadius and DefaultWalkingSpeed are used.
+func WithWalking(radius float64, speed float64) Option {
+	return func(t *Timetable) {
+		t.walkingRadius = radius
+		t.walkingSpeed = speed
+	}
+}
+
+// QueryCoordinates computes the fastest route between two positions, e.g. addresses. The passenger may walk
+// from the origin to all stops within the walking radius, and from all stops within the walking radius to
+// the destination, see WithWalking. If origin and destination are within the walking radius, walking directly is
+// considered, too. The walking duration is the great-circle distance divided by the walking speed, rounded up to
+// full minutes. Only stops with a position are considered.
+//
+// The connection starts and ends with walking legs. Their first and last stops are new stops with the ids "origin"
+// and "destination" and the given positions. The errors are the same as the errors of Query.
+func (t *Timetable) QueryCoordinates(origin Coordinate, destination Coordinate, start time.Time) (*Connection, error) {
+	if t.invalid != nil {
+		return nil, t.invalid
+	}
+	if t.index == nil {
+		// the timetable was not created with NewTimetable, thus it has no stops
+		return nil, ErrNoConnection
+	}
+	originStop := &Stop{Id: "origin", Name: "Origin", Position: &origin}
+	destinationStop := &Stop{Id: "destination", Name: "Destination", Position: &destination}
+	first := &label{vertex: &vertex{data: originStop, id: -1}, weight: start}
+	labels := t.graph.labels()
+	sources := make([]*label, 0, 0)
+	for _, stop := range t.StopsWithin(origin, t.walkingRadius) {
+		l := &labels[t.stops[stop.Id].id]
+		footpath := &Footpath{From: originStop, To: stop, Duration: t.walkingDuration(origin, *stop.Position)}
+		*l = label{vertex: l.vertex, footpath: footpath, departure: start, weight: start.Add(footpath.Duration), predecessor: first}
+		sources = append(sources, l)
+	}
+	egress := make(map[*vertex]time.Duration)
+	for _, stop := range t.StopsWithin(destination, t.walkingRadius) {
+		egress[t.stops[stop.Id]] = t.walkingDuration(*stop.Position, destination)
+	}
+	var last *label
+	if origin.Distance(destination) <= t.walkingRadius {
+		footpath := &Footpath{From: originStop, To: destinationStop, Duration: t.walkingDuration(origin, destination)}
+		last = &label{vertex: &vertex{data: destinationStop, id: -1}, footpath: footpath, departure: start, weight: start.Add(footpath.Duration), predecessor: first}
+	}
+	t.graph.search(labels, sources, start.Add(t.horizon), nil, func(l *label) bool {
+		if last != nil && !l.weight.Before(last.weight) {
+			// all following labels arrive later, thus walking from them cannot be faster
+			return true
+		}
+		duration, ok := egress[l.vertex]
+		if !ok {
+			return false
+		}
+		if arrival := l.weight.Add(duration); last == nil || arrival.Before(last.weight) {
+			footpath := &Footpath{From: l.vertex.data, To: destinationStop, Duration: duration}
+			last = &label{vertex: &vertex{data: destinationStop, id: -1}, footpath: footpath, departure: l.weight, weight: arrival, predecessor: l}
+		}
+		return false
+	})
+	if last == nil {
+		return nil, ErrNoConnection
+	}
+	return createConnection(pathTo(last)), nil
+}
+
+// walkingDuration returns the time needed to walk between the positions, rounded up to full minutes.
+func (t *Timetable) walkingDuration(from Coordinate, to Coordinate) time.Duration {
+	seconds := from.Distance(to) / t.walkingSpeed
+	return time.Duration(math.Ceil(seconds/60)) * time.Minute
+}
@@ -0,0 +1,79 @@
+package routing
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+)
+
+func TestTimetable_QueryCoordinates(t *testing.T) {
+	// north returns the position the given number of meters north of a reference point
+	north := func(meters float64) Coordinate {
+		return Coordinate{Lat: 49.8 + meters/earthRadius*180/math.Pi, Lon: 9.9}
+	}
+	network := createTestNetwork()
+	for _, stop := range []struct {
+		stop   *Stop
+		meters float64
+	}{
+		{network.northEnd, 0}, {network.northAvenue, 2000}, {network.mainStation, 4000}, {network.docksAE, 6000},
+		{network.docksFG, 6400}, {network.airport, 9000}, {network.historicMall, 12000}, {network.schusterStreet, 14000},
+		{network.chalet, 15000},
+	} {
+		position := north(stop.meters)
+		stop.stop.Position = &position
+	}
+	timetable := NewTimetable(network.stops())
+
+	t.Run("access and egress", func(t *testing.T) {
+		connection, err := timetable.QueryCoordinates(north(-300), north(14300), date("10:20"))
+		require.NoError(t, err)
+		// chalet is reached two minutes later than schuster street, but walking from there takes ten minutes instead of five
+		assert.Equal(t, date("10:56"), connection.Arrival, "arrival is wrong")
+		require.Equal(t, 4, len(connection.Legs), "number of legs")
+		access := connection.Legs[0]
+		assert.Equal(t, WalkingLeg, access.Kind, "first leg should be walking")
+		assert.Equal(t, "origin", access.FirstStop.Id, "first stop is wrong")
+		assert.Equal(t, north(-300), *access.FirstStop.Position, "position of origin is wrong")
+		assert.Equal(t, network.northEnd, access.LastStop, "first stop to walk to is wrong")
+		assert.Equal(t, date("10:20"), access.Departure, "departure is wrong")
+		assert.Equal(t, date("10:25"), access.Arrival, "arrival at the first stop is wrong")
+		assert.Equal(t, network.redLine, connection.Legs[1].Line, "line of the second leg is wrong")
+		assert.Equal(t, network.blueLine, connection.Legs[2].Line, "line of the third leg is wrong")
+		egress := connection.Legs[3]
+		assert.Equal(t, WalkingLeg, egress.Kind, "last leg should be walking")
+		assert.Equal(t, network.schusterStreet, egress.FirstStop, "last stop is wrong")
+		assert.Equal(t, "destination", egress.LastStop.Id, "last stop is wrong")
+		assert.Equal(t, date("10:51"), egress.Departure, "departure at the last stop is wrong")
+	})
+	t.Run("walking directly", func(t *testing.T) {
+		connection, err := timetable.QueryCoordinates(north(6500), north(7000), date("10:20"))
+		require.NoError(t, err)
+		require.Equal(t, 1, len(connection.Legs), "number of legs")
+		assert.Equal(t, WalkingLeg, connection.Legs[0].Kind, "leg should be walking")
+		assert.Equal(t, date("10:27"), connection.Arrival, "arrival is wrong")
+	})
+	t.Run("walking radius", func(t *testing.T) {
+		small := NewTimetable(network.stops(), WithWalking(200, DefaultWalkingSpeed))
+		connection, err := small.QueryCoordinates(north(-300), north(14300), date("10:20"))
+		assert.Equal(t, ErrNoConnection, err, "error is wrong")
+		assert.Nil(t, connection, "no connection should be returned")
+
+		slow := NewTimetable(network.stops(), WithWalking(DefaultWalkingRadius, 0.5))
+		connection, err = slow.QueryCoordinates(north(-300), north(14300), date("10:20"))
+		require.NoError(t, err)
+		assert.Equal(t, date("10:30"), connection.Legs[0].Arrival, "walking should take ten minutes")
+	})
+	t.Run("no connection", func(t *testing.T) {
+		connection, err := timetable.QueryCoordinates(north(-5000), north(14300), date("10:20"))
+		assert.Equal(t, ErrNoConnection, err, "error is wrong")
+		assert.Nil(t, connection, "no connection should be returned")
+	})
+	t.Run("zero timetable", func(t *testing.T) {
+		zero := Timetable{}
+		connection, err := zero.QueryCoordinates(north(0), north(0), date("10:20"))
+		assert.Equal(t, ErrNoConnection, err, "error is wrong")
+		assert.Nil(t, connection, "no connection should be returned")
+	})
+}
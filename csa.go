@@ -0,0 +1,257 @@
+package routing
+
+import (
+	"sort"
+	"time"
+)
+
+// ConnectionScan is a router which implements the Connection Scan Algorithm (CSA). It does not use a
+// graph, but a single array of all elementary connections of the timetable, i.e. departures of vehicles
+// to their next stops, sorted by departure. A query scans the array once in the order of departure
+// and remembers the earliest arrival at every stop and which runs of vehicles can be used.
+//
+// ConnectionScan uses the stops, footpaths, transfer policy, and search horizon of its timetable. Like
+// Raptor, it continues with every vehicle along its run, thus its connections may arrive earlier than the connections
+// of Query of the timetable if a stop is reached by two vehicles at the same time.
+type ConnectionScan struct {
+	timetable   *Timetable
+	connections []elementaryConnection
+	runs        int
+	daysBack    int
+	footpaths   [][]*Footpath
+	invalid     error
+}
+
+// elementaryConnection is a departure of a vehicle at a stop to the next stop, both given by the ids of their vertices.
+// The offsets are relative to the service day. Next is the index of the connection which continues the run
+// of the vehicle, or -1 if the run ends.
+type elementaryConnection struct {
+	from      int
+	to        int
+	departure time.Duration
+	arrival   time.Duration
+	event     *Event
+	run       int
+	next      int
+}
+
+// scanLabel contains the earliest arrival at a stop during a scan and how the stop was reached:
+// either with the connections enter to exit of a run on the given service day, or with a footpath from another stop.
+// All times are relative to the start of the scan.
+type scanLabel struct {
+	reached   bool
+	ride      bool
+	arrival   time.Duration
+	departure time.Duration
+	event     *Event
+	enter     int
+	exit      int
+	day       time.Duration
+	footpath  *Footpath
+	from      int
+}
+
+// NewConnectionScan creates the CSA router of the timetable by sorting all of its departures.
+// If the timetable contains invalid times, then Query of the router returns ErrInvalidTime.
+func NewConnectionScan(timetable *Timetable) *ConnectionScan {
+	vertices := timetable.graph.vertices
+	result := &ConnectionScan{timetable: timetable, footpaths: make([][]*Footpath, len(vertices))}
+	for i := range timetable.footpaths {
+		footpath := &timetable.footpaths[i]
+		if timetable.contains(footpath.From) && timetable.contains(footpath.To) {
+			from := timetable.stops[footpath.From.Id].id
+			result.footpaths[from] = append(result.footpaths[from], footpath)
+		}
+	}
+	heads, err := timetable.runs()
+	if timetable.invalid != nil || err != nil {
+		result.invalid = timetable.invalid
+		if result.invalid == nil {
+			result.invalid = err
+		}
+		return result
+	}
+	indices := make(map[*chainedEvent]int)
+	for run, c := range heads {
+		for ; c != nil; c = c.next {
+			indices[c] = len(result.connections)
+			connection := elementaryConnection{
+				from:      timetable.stops[c.stop.Id].id,
+				to:        timetable.stops[c.event.NextStop.Id].id,
+				departure: c.offset,
+				arrival:   c.arrival,
+				event:     c.event,
+				run:       run,
+				next:      -1,
+			}
+			result.connections = append(result.connections, connection)
+			if days := int(c.offset / (24 * time.Hour)); days > result.daysBack {
+				result.daysBack = days
+			}
+		}
+	}
+	for _, c := range heads {
+		for ; c.next != nil; c = c.next {
+			result.connections[indices[c]].next = indices[c.next]
+		}
+	}
+	order := make([]int, len(result.connections))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := &result.connections[order[i]], &result.connections[order[j]]
+		if a.departure == b.departure {
+			return a.arrival < b.arrival
+		}
+		return a.departure < b.departure
+	})
+	sorted := make([]elementaryConnection, len(order))
+	moved := make([]int, len(order))
+	for i, index := range order {
+		sorted[i] = result.connections[index]
+		moved[index] = i
+	}
+	for i := range sorted {
+		if sorted[i].next >= 0 {
+			sorted[i].next = moved[sorted[i].next]
+		}
+	}
+	result.connections = sorted
+	result.runs = len(heads)
+	return result
+}
+
+// Query computes the fastest route between source and target with the specified start time.
+// The errors are the same as the errors of Query of the timetable.
+func (c *ConnectionScan) Query(source *Stop, target *Stop, start time.Time) (*Connection, error) {
+	s, err := c.timetable.vertex(source, "source")
+	if err != nil {
+		return nil, err
+	}
+	ta, err := c.timetable.vertex(target, "target")
+	if err != nil {
+		return nil, err
+	}
+	if c.invalid != nil {
+		return nil, c.invalid
+	}
+	labels := c.scan(s.id, ta.id, start, c.timetable.horizon)
+	if !labels[ta.id].reached {
+		return nil, ErrNoConnection
+	}
+	connection := createConnection(c.path(labels, ta.id, start))
+	if connection == nil {
+		return nil, ErrNoConnection
+	}
+	return connection, nil
+}
+
+// scan computes the earliest arrivals at all stops. The connections of all service days which may depart
+// between start and start plus horizon are merged in the order of their departures. The scan stops as soon as the
+// departures are not earlier than the arrival at t.
+func (c *ConnectionScan) scan(s int, t int, start time.Time, horizon time.Duration) []scanLabel {
+	labels := make([]scanLabel, len(c.timetable.graph.vertices))
+	improves := func(id int, arrival time.Duration) bool {
+		return (!labels[id].reached || arrival < labels[id].arrival) && (!labels[t].reached || arrival < labels[t].arrival)
+	}
+	labels[s] = scanLabel{reached: true}
+	c.walk(labels, s, improves)
+	// the service days relative to the start and the positions of their next connections
+	days := make([]time.Duration, 0, c.daysBack+2)
+	dates := make([]time.Time, 0, c.daysBack+2)
+	positions := make([]int, 0, c.daysBack+2)
+	deadline := start.Add(horizon)
+	day := time.Date(start.Year(), start.Month(), start.Day()-c.daysBack, 0, 0, 0, 0, start.Location())
+	for ; !day.After(deadline); day = day.AddDate(0, 0, 1) {
+		offset := day.Sub(start)
+		days = append(days, offset)
+		dates = append(dates, day)
+		positions = append(positions, sort.Search(len(c.connections), func(i int) bool {
+			return c.connections[i].departure >= -offset
+		}))
+	}
+	// the connection of every run and day by which the run was entered plus one, or 0 if the run was not entered
+	entered := make([]int, len(days)*c.runs)
+	for {
+		next := -1
+		var departure time.Duration
+		for i, day := range days {
+			if positions[i] == len(c.connections) {
+				continue
+			}
+			candidate := day + c.connections[positions[i]].departure
+			if next < 0 || candidate < departure {
+				next = i
+				departure = candidate
+			}
+		}
+		if next < 0 || departure > horizon || (labels[t].reached && departure >= labels[t].arrival) {
+			break
+		}
+		index := positions[next]
+		positions[next]++
+		connection := &c.connections[index]
+		run := next*c.runs + connection.run
+		if entered[run] == 0 {
+			from := &labels[connection.from]
+			if !from.reached || departure < from.arrival || !connection.event.runsOn(dates[next]) {
+				continue
+			}
+			stop := c.timetable.graph.vertices[connection.from].data
+			if departure < from.arrival+c.timetable.policy.eventTransferTime(stop, from.event, connection.event) {
+				continue
+			}
+			entered[run] = index + 1
+		}
+		arrival := days[next] + connection.arrival
+		if improves(connection.to, arrival) {
+			labels[connection.to] = scanLabel{reached: true, ride: true, arrival: arrival, event: connection.event, enter: entered[run] - 1, exit: index, day: days[next]}
+			c.walk(labels, connection.to, improves)
+		}
+	}
+	return labels
+}
+
+// walk uses the footpaths starting at the stop in order to improve other labels. Footpaths may be chained.
+func (c *ConnectionScan) walk(labels []scanLabel, id int, improves func(int, time.Duration) bool) {
+	queue := []int{id}
+	for len(queue) != 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, footpath := range c.footpaths[id] {
+			target := c.timetable.stops[footpath.To.Id].id
+			departure := labels[id].arrival
+			arrival := departure + footpath.Duration
+			if !improves(target, arrival) {
+				continue
+			}
+			labels[target] = scanLabel{reached: true, arrival: arrival, departure: departure, footpath: footpath, from: id}
+			queue = append(queue, target)
+		}
+	}
+}
+
+// path converts the labels leading to the stop into a path of the graph.
+func (c *ConnectionScan) path(labels []scanLabel, id int, start time.Time) []*label {
+	vertices := c.timetable.graph.vertices
+	l := labels[id]
+	if !l.ride && l.footpath == nil {
+		return []*label{{vertex: vertices[id], weight: start.Add(l.arrival)}}
+	}
+	if l.footpath != nil {
+		result := c.path(labels, l.from, start)
+		return append(result, &label{vertex: vertices[id], footpath: l.footpath, departure: start.Add(l.departure), weight: start.Add(l.arrival), predecessor: result[len(result)-1]})
+	}
+	result := c.path(labels, c.connections[l.enter].from, start)
+	day := start.Add(l.day)
+	for index := l.enter; ; index = c.connections[index].next {
+		connection := &c.connections[index]
+		next := &label{vertex: vertices[connection.to], event: connection.event, departure: day.Add(connection.departure),
+			weight: day.Add(connection.arrival), predecessor: result[len(result)-1]}
+		result = append(result, next)
+		if index == l.exit {
+			return result
+		}
+	}
+}
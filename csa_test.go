@@ -0,0 +1,29 @@
+package routing
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConnectionScan_Query(t *testing.T) {
+	testRouter(t, func(timetable *Timetable) Router {
+		return NewConnectionScan(timetable)
+	})
+	t.Run("raptor", func(t *testing.T) {
+		grid := createGridNetwork(5, 10)
+		timetable := NewTimetable(flattenGrid(grid))
+		scan := NewConnectionScan(&timetable)
+		raptor := NewRaptor(&timetable)
+		for _, source := range flattenGrid(grid) {
+			for _, target := range flattenGrid(grid) {
+				expected, err := raptor.Query(source, target, date("8:00"))
+				actual, scanErr := scan.Query(source, target, date("8:00"))
+				require.Equal(t, err, scanErr, "errors differ for %s -> %s", source.Id, target.Id)
+				if err == nil {
+					assert.Equal(t, expected.Arrival, actual.Arrival, "%s -> %s: arrival is wrong", source.Id, target.Id)
+				}
+			}
+		}
+	})
+}
@@ -13,4 +13,7 @@
 // real date and time (time.Time type). The departure times are then interpreted to take place at the certain date.
 // In order to simulate timetables spanning more than one day, departure times can also be given
 // four hours bigger than 23 o'clock, e.g. 26:34 means 02:34 on the second day.
+// Queries consider the departures of the previous and the following days, too, thus a query at 01:00 finds
+// the departure 25:10 of the previous day, and a query at 23:50 finds the departures of the next morning.
+// How far a query looks into the future is limited by the search horizon, see WithSearchHorizon.
 package routing
@@ -0,0 +1,14 @@
+package routing
+
+import "errors"
+
+var (
+	// ErrUnknownStop is returned if a queried stop is not part of the timetable.
+	ErrUnknownStop = errors.New("stop not found in the timetable")
+	// ErrInvalidTime is returned if a time string does not match the TimeRegex.
+	ErrInvalidTime = errors.New("time does not match the required format")
+	// ErrNoConnection is returned if there is no connection between the queried stops.
+	ErrNoConnection = errors.New("no connection found")
+	// ErrInvalidFeed is returned if a GTFS feed cannot be loaded because it is malformed.
+	ErrInvalidFeed = errors.New("invalid GTFS feed")
+)
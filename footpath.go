@@ -0,0 +1,27 @@
+package routing
+
+import "time"
+
+// Footpath is a walking connection between two nearby stops. It can be used at any time
+// and takes the passenger from the stop From to the stop To within the given Duration.
+// Footpaths are directed, thus a footpath in the opposite direction must be defined separately.
+// Because the walking duration already contains the time for changing the platform, no
+// additional transfer time is needed after walking.
+type Footpath struct {
+	From     *Stop
+	To       *Stop
+	Duration time.Duration
+}
+
+// WithFootpaths registers the given footpaths in the timetable.
+func WithFootpaths(footpaths ...Footpath) Option {
+	return func(t *Timetable) {
+		t.footpaths = append(t.footpaths, footpaths...)
+	}
+}
+
+func (f *Footpath) weightFunction() edgeWeight {
+	return func(deadline time.Time, t time.Time, currentEvent *Event) (hop, bool) {
+		return hop{footpath: f, departure: t, arrival: t.Add(f.Duration)}, true
+	}
+}
@@ -0,0 +1,48 @@
+package routing
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestTimetable_QueryFootpaths(t *testing.T) {
+	network := createTestNetwork()
+	timetable := NewTimetable(network.stops(), WithFootpaths(
+		Footpath{From: network.docksAE, To: network.docksFG, Duration: 4 * time.Minute},
+		Footpath{From: network.docksFG, To: network.docksAE, Duration: 4 * time.Minute},
+	))
+
+	t.Run("walk at the end", func(t *testing.T) {
+		connection, err := timetable.Query(network.mainStation, network.docksFG, date("10:00"))
+		require.NoError(t, err)
+		require.Equal(t, 2, len(connection.Legs), "number of legs in the connection")
+		assert.Equal(t, TransitLeg, connection.Legs[0].Kind, "kind of first leg is wrong")
+		assert.Equal(t, network.redLine, connection.Legs[0].Line, "line of first leg is wrong")
+		assert.Equal(t, WalkingLeg, connection.Legs[1].Kind, "kind of second leg is wrong")
+		assert.Nil(t, connection.Legs[1].Line, "walking legs have no line")
+		assert.Equal(t, network.docksAE, connection.Legs[1].FirstStop, "first stop of second leg is wrong")
+		assert.Equal(t, network.docksFG, connection.Legs[1].LastStop, "last stop of second leg is wrong")
+		assert.Equal(t, date("10:07"), connection.Legs[1].Departure, "departure of second leg is wrong")
+		assert.Equal(t, 0*time.Minute, connection.Legs[1].Wait, "walking legs start immediately")
+		assert.Equal(t, date("10:11"), connection.Arrival, "time is wrong")
+	})
+	t.Run("walk at the beginning", func(t *testing.T) {
+		connection, err := timetable.Query(network.docksFG, network.airport, date("10:00"))
+		require.NoError(t, err)
+		require.Equal(t, 2, len(connection.Legs), "number of legs in the connection")
+		assert.Equal(t, WalkingLeg, connection.Legs[0].Kind, "kind of first leg is wrong")
+		assert.Equal(t, date("10:04"), connection.Legs[0].Arrival, "arrival of first leg is wrong")
+		assert.Equal(t, TransitLeg, connection.Legs[1].Kind, "kind of second leg is wrong")
+		assert.Equal(t, network.redLine, connection.Legs[1].Line, "line of second leg is wrong")
+		assert.Equal(t, 3*time.Minute, connection.Legs[1].Wait, "no transfer time is needed after walking")
+		assert.Equal(t, date("10:12"), connection.Arrival, "time is wrong")
+	})
+	t.Run("without footpaths", func(t *testing.T) {
+		withoutFootpaths := NewTimetable(network.stops())
+		connection, err := withoutFootpaths.Query(network.mainStation, network.docksFG, date("10:00"))
+		assert.Equal(t, ErrNoConnection, err, "docks F and G can only be reached by walking")
+		assert.Nil(t, connection, "docks F and G can only be reached by walking")
+	})
+}
@@ -0,0 +1,184 @@
+package routing
+
+import (
+	"math"
+	"sort"
+)
+
+// earthRadius is the mean radius of the earth in meters.
+const earthRadius = 6371000.0
+
+// Coordinate is a position on earth, given by latitude and longitude in degrees (WGS84).
+type Coordinate struct {
+	Lat float64
+	Lon float64
+}
+
+// Distance returns the great-circle distance between the coordinates in meters, computed with the haversine formula.
+func (c Coordinate) Distance(other Coordinate) float64 {
+	lat1, lat2 := c.Lat*math.Pi/180, other.Lat*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (other.Lon - c.Lon) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// point returns the coordinate as point on the unit sphere. The euclidean distance between two
+// points grows with the great-circle distance between their coordinates, thus points can be used in a k-d tree.
+func (c Coordinate) point() [3]float64 {
+	lat, lon := c.Lat*math.Pi/180, c.Lon*math.Pi/180
+	return [3]float64{math.Cos(lat) * math.Cos(lon), math.Cos(lat) * math.Sin(lon), math.Sin(lat)}
+}
+
+// Distance returns the great-circle distance between the stops in meters.
+// If the position of one of the stops is unknown, then false is returned.
+func (s *Stop) Distance(other *Stop) (float64, bool) {
+	if s.Position == nil || other.Position == nil {
+		return 0, false
+	}
+	return s.Position.Distance(*other.Position), true
+}
+
+// StopPosition is the Locator returning the position of the stop.
+func StopPosition(stop *Stop) (Coordinate, bool) {
+	if stop.Position == nil {
+		return Coordinate{}, false
+	}
+	return *stop.Position, true
+}
+
+// NearestStops returns the given number of stops of the timetable which are nearest to the position,
+// sorted by their distance. Stops without position are not considered.
+func (t *Timetable) NearestStops(position Coordinate, count int) []*Stop {
+	candidates := make([]indexCandidate, 0, count)
+	if count > 0 && t.index != nil {
+		t.index.root.nearest(position.point(), count, &candidates)
+	}
+	return candidateStops(candidates)
+}
+
+// StopsWithin returns all stops of the timetable whose distance to the position is at most the radius (in meters),
+// sorted by their distance. Stops without position are not considered.
+func (t *Timetable) StopsWithin(position Coordinate, radius float64) []*Stop {
+	candidates := make([]indexCandidate, 0, 0)
+	if radius >= 0 && t.index != nil {
+		// the euclidean distance between points on the unit sphere corresponding to the radius
+		chord := 2 * math.Sin(math.Min(radius/earthRadius, math.Pi)/2)
+		t.index.root.within(position.point(), chord*chord, &candidates)
+	}
+	sortCandidates(candidates)
+	return candidateStops(candidates)
+}
+
+// stopIndex is a k-d tree containing the stops with positions.
+type stopIndex struct {
+	root *indexNode
+}
+
+type indexNode struct {
+	stop  *Stop
+	point [3]float64
+	axis  int
+	left  *indexNode
+	right *indexNode
+}
+
+// indexCandidate is a stop found in the index and its squared euclidean distance to the searched point.
+type indexCandidate struct {
+	stop     *Stop
+	distance float64
+}
+
+func newStopIndex(stops []*Stop) *stopIndex {
+	nodes := make([]*indexNode, 0, len(stops))
+	for _, stop := range stops {
+		if stop.Position != nil {
+			nodes = append(nodes, &indexNode{stop: stop, point: stop.Position.point()})
+		}
+	}
+	return &stopIndex{root: buildIndex(nodes, 0)}
+}
+
+// buildIndex builds the tree by splitting the nodes at the median of the axis, which alternates between the levels.
+func buildIndex(nodes []*indexNode, axis int) *indexNode {
+	if len(nodes) == 0 {
+		return nil
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].point[axis] < nodes[j].point[axis]
+	})
+	median := len(nodes) / 2
+	node := nodes[median]
+	node.axis = axis
+	node.left = buildIndex(nodes[:median], (axis+1)%3)
+	node.right = buildIndex(nodes[median+1:], (axis+1)%3)
+	return node
+}
+
+func squaredDistance(a [3]float64, b [3]float64) float64 {
+	result := 0.0
+	for i := range a {
+		result += (a[i] - b[i]) * (a[i] - b[i])
+	}
+	return result
+}
+
+// nearest adds the nodes of the subtree to the candidates, which are sorted by distance and contain at most count nodes.
+// Subtrees which cannot contain nodes nearer than the farthest candidate are skipped.
+func (n *indexNode) nearest(point [3]float64, count int, candidates *[]indexCandidate) {
+	if n == nil {
+		return
+	}
+	distance := squaredDistance(point, n.point)
+	if len(*candidates) < count || distance < (*candidates)[len(*candidates)-1].distance {
+		if len(*candidates) == count {
+			*candidates = (*candidates)[:count-1]
+		}
+		*candidates = append(*candidates, indexCandidate{stop: n.stop, distance: distance})
+		sortCandidates(*candidates)
+	}
+	near, far := n.left, n.right
+	delta := point[n.axis] - n.point[n.axis]
+	if delta > 0 {
+		near, far = far, near
+	}
+	near.nearest(point, count, candidates)
+	if len(*candidates) < count || delta*delta < (*candidates)[len(*candidates)-1].distance {
+		far.nearest(point, count, candidates)
+	}
+}
+
+// within adds all nodes of the subtree whose squared distance to the point is at most the limit to the candidates.
+func (n *indexNode) within(point [3]float64, limit float64, candidates *[]indexCandidate) {
+	if n == nil {
+		return
+	}
+	if distance := squaredDistance(point, n.point); distance <= limit {
+		*candidates = append(*candidates, indexCandidate{stop: n.stop, distance: distance})
+	}
+	delta := point[n.axis] - n.point[n.axis]
+	if delta <= 0 || delta*delta <= limit {
+		n.left.within(point, limit, candidates)
+	}
+	if delta >= 0 || delta*delta <= limit {
+		n.right.within(point, limit, candidates)
+	}
+}
+
+// sortCandidates sorts the candidates by their distance, candidates with the same distance by the ids of their stops.
+func sortCandidates(candidates []indexCandidate) {
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance == candidates[j].distance {
+			return candidates[i].stop.Id < candidates[j].stop.Id
+		}
+		return candidates[i].distance < candidates[j].distance
+	})
+}
+
+func candidateStops(candidates []indexCandidate) []*Stop {
+	result := make([]*Stop, 0, len(candidates))
+	for _, candidate := range candidates {
+		result = append(result, candidate.stop)
+	}
+	return result
+}
@@ -0,0 +1,98 @@
+package routing
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestCoordinate_Distance(t *testing.T) {
+	berlin := Coordinate{Lat: 52.52, Lon: 13.405}
+	paris := Coordinate{Lat: 48.8566, Lon: 2.3522}
+	assert.InDelta(t, 877463.3, berlin.Distance(paris), 0.1, "distance between Berlin and Paris is wrong")
+	assert.InDelta(t, 877463.3, paris.Distance(berlin), 0.1, "distance should be symmetric")
+	assert.Equal(t, 0.0, berlin.Distance(berlin), "distance to itself should be zero")
+	assert.InDelta(t, 22239.0, Coordinate{Lon: 179.9}.Distance(Coordinate{Lon: -179.9}), 0.1, "distance across the antimeridian is wrong")
+}
+
+func TestStop_Distance(t *testing.T) {
+	a := NewStop("A", "A")
+	b := NewStop("B", "B")
+	_, ok := a.Distance(b)
+	assert.False(t, ok, "distance without positions should not be known")
+	a.Position = &Coordinate{Lat: 52.52, Lon: 13.405}
+	b.Position = &Coordinate{Lat: 48.8566, Lon: 2.3522}
+	distance, ok := a.Distance(b)
+	assert.True(t, ok, "distance should be known")
+	assert.InDelta(t, 877463.3, distance, 0.1, "distance is wrong")
+}
+
+func TestTimetable_NearestStops(t *testing.T) {
+	random := rand.New(rand.NewSource(42))
+	stops := make([]*Stop, 0, 500)
+	for i := 0; i < 500; i++ {
+		stop := NewStop(fmt.Sprintf("%d", i), "Stop")
+		if i%10 != 0 {
+			stop.Position = &Coordinate{Lat: 49.7 + random.Float64()*0.2, Lon: 9.8 + random.Float64()*0.3}
+		}
+		stops = append(stops, stop)
+	}
+	antimeridian := NewStop("AM", "Antimeridian")
+	antimeridian.Position = &Coordinate{Lat: 0, Lon: -179.99}
+	stops = append(stops, antimeridian)
+	timetable := NewTimetable(stops)
+	// sortedByDistance returns all stops with position sorted by their distance to the position
+	sortedByDistance := func(position Coordinate) []*Stop {
+		result := make([]*Stop, 0, len(stops))
+		for _, stop := range stops {
+			if stop.Position != nil {
+				result = append(result, stop)
+			}
+		}
+		sort.SliceStable(result, func(i, j int) bool {
+			return position.Distance(*result[i].Position) < position.Distance(*result[j].Position)
+		})
+		return result
+	}
+
+	t.Run("nearest", func(t *testing.T) {
+		for i := 0; i < 20; i++ {
+			position := Coordinate{Lat: 49.65 + random.Float64()*0.3, Lon: 9.75 + random.Float64()*0.4}
+			expected := sortedByDistance(position)
+			assert.Equal(t, expected[:5], timetable.NearestStops(position, 5), "nearest stops of %v are wrong", position)
+		}
+	})
+	t.Run("count", func(t *testing.T) {
+		assert.Equal(t, 451, len(timetable.NearestStops(Coordinate{Lat: 49.8, Lon: 9.9}, 1000)), "all stops with position should be returned")
+		assert.Equal(t, 0, len(timetable.NearestStops(Coordinate{Lat: 49.8, Lon: 9.9}, 0)), "no stop should be returned")
+		empty := NewTimetable([]*Stop{NewStop("A", "A")})
+		assert.Equal(t, 0, len(empty.NearestStops(Coordinate{Lat: 49.8, Lon: 9.9}, 3)), "no stop should be returned")
+	})
+	t.Run("zero timetable", func(t *testing.T) {
+		zero := Timetable{}
+		assert.Equal(t, 0, len(zero.NearestStops(Coordinate{Lat: 49.8, Lon: 9.9}, 3)), "no stop should be returned")
+		assert.Equal(t, 0, len(zero.StopsWithin(Coordinate{Lat: 49.8, Lon: 9.9}, 500)), "no stop should be returned")
+	})
+	t.Run("within", func(t *testing.T) {
+		for _, radius := range []float64{0, 500, 1500, 4000} {
+			position := Coordinate{Lat: 49.8, Lon: 9.95}
+			expected := make([]*Stop, 0, 0)
+			for _, stop := range sortedByDistance(position) {
+				if position.Distance(*stop.Position) <= radius {
+					expected = append(expected, stop)
+				}
+			}
+			assert.Equal(t, expected, timetable.StopsWithin(position, radius), "stops within %f are wrong", radius)
+		}
+		assert.Equal(t, 451, len(timetable.StopsWithin(Coordinate{}, 30000000)), "all stops should be within the radius")
+	})
+	t.Run("antimeridian", func(t *testing.T) {
+		nearest := timetable.NearestStops(Coordinate{Lat: 0, Lon: 179.99}, 1)
+		require.Equal(t, 1, len(nearest), "number of stops")
+		assert.Equal(t, antimeridian, nearest[0], "stop on the other side of the antimeridian should be nearest")
+		assert.Equal(t, []*Stop{antimeridian}, timetable.StopsWithin(Coordinate{Lat: 0, Lon: 179.99}, 3000), "stops within are wrong")
+	})
+}
@@ -5,63 +5,147 @@ import (
 	"time"
 )
 
+// vertex is the representation of a stop in the graph. Vertices and their edges are created once
+// and must not be changed by searches, because a graph may be searched concurrently.
 type vertex struct {
-	currentLine *Line
-	data        *Stop
-	neighbors   []edge
+	data      *Stop
+	id        int
+	neighbors []edge
+}
+
+// label contains the state of a vertex during a single search.
+type label struct {
+	vertex      *vertex
+	event       *Event
+	footpath    *Footpath
+	departure   time.Time
 	weight      time.Time
 	index       int
-	predecessor *vertex
+	settled     bool
+	predecessor *label
 }
 
-type edgeWeight func(time time.Time, currentLine *Line) (time.Duration, *Line, bool)
+// hop describes how a vertex is reached from its predecessor: the event or the footpath that was
+// used as well as the departure at the predecessor and the arrival at the vertex.
+type hop struct {
+	event     *Event
+	footpath  *Footpath
+	departure time.Time
+	arrival   time.Time
+}
+
+// edgeWeight computes how to get to the target of the edge as fast as possible if the passenger
+// arrives at the edge's source at the given time. Departures after the deadline must not be used.
+type edgeWeight func(deadline time.Time, time time.Time, currentEvent *Event) (hop, bool)
+
+// constrainedWeight works like edgeWeight, but only departures whose events are allowed are used.
+// If allowed is nil, then all departures are allowed.
+type constrainedWeight func(deadline time.Time, time time.Time, currentEvent *Event, allowed func(*Event) bool) (hop, bool)
 
+// edge connects two vertices. The constrained weight only uses the allowed departures, it is nil for footpaths.
 type edge struct {
-	weight edgeWeight
-	target *vertex
+	weight      edgeWeight
+	constrained constrainedWeight
+	target      *vertex
 }
 
 type graph struct {
 	vertices []*vertex
 }
 
-func (g *graph) shortestPath(s *vertex, t *vertex, start time.Time) []*vertex {
+// shortestPath computes the fastest path from s to t. All state of the search
+// is kept in labels local to the call, thus the method can be called concurrently.
+// Vertices are only added to the priority queue once they are reached and the search
+// stops as soon as the arrival time at t is final. Departures after the deadline are ignored.
+func (g *graph) shortestPath(s *vertex, t *vertex, start time.Time, deadline time.Time) []*label {
+	labels := g.earliestArrivals(s, t, start, deadline)
+	return pathTo(&labels[t.id])
+}
+
+// earliestArrivals runs the search of shortestPath and returns the labels of all vertices, indexed by their ids.
+// If t is nil, the search does not stop early, thus the labels contain the earliest arrivals at all vertices.
+// Labels of vertices which are not reached have a zero weight.
+func (g *graph) earliestArrivals(s *vertex, t *vertex, start time.Time, deadline time.Time) []label {
+	labels := g.labels()
+	labels[s.id].weight = start
+	g.search(labels, []*label{&labels[s.id]}, deadline, nil, func(l *label) bool {
+		return l.vertex == t
+	})
+	return labels
+}
+
+// labels creates the labels of all vertices for a search.
+func (g *graph) labels() []label {
+	labels := make([]label, len(g.vertices))
+	for i, vertex := range g.vertices {
+		labels[i].vertex = vertex
+	}
+	return labels
+}
+
+// constraints restrict a search: avoided vertices, indexed by their ids, are never reached,
+// and only departures whose events are allowed are used.
+type constraints struct {
+	avoided []bool
+	allowed func(*Event) bool
+}
+
+// search runs Dijkstra's algorithm starting with the given labels, which must be part of labels and already
+// have their weights. The search stops when the queue is empty or done returns true for a settled label.
+// If constraints are given, then the search only uses the vertices and departures allowed by them.
+func (g *graph) search(labels []label, sources []*label, deadline time.Time, constraints *constraints, done func(*label) bool) {
 	priorityQueue := &priorityQueue{}
-	for _, vertex := range g.vertices {
-		vertex.weight = time.Time{}
-		vertex.predecessor = nil
-		vertex.currentLine = nil
-		priorityQueue.Push(vertex)
+	for _, source := range sources {
+		heap.Push(priorityQueue, source)
 	}
-	s.weight = start
-	heap.Init(priorityQueue)
 	for len(*priorityQueue) != 0 {
-		v := heap.Pop(priorityQueue).(*vertex)
-		if (v.weight == time.Time{}) {
-			// v was not reached before and cannot be used for the route
-			continue
+		l := heap.Pop(priorityQueue).(*label)
+		l.settled = true
+		if done(l) {
+			break
 		}
-		for _, edge := range v.neighbors {
-			neighbour := edge.target
-			weight, line, ok := edge.weight(v.weight, v.currentLine)
+		for _, edge := range l.vertex.neighbors {
+			neighbour := &labels[edge.target.id]
+			if neighbour.settled {
+				continue
+			}
+			var hop hop
+			var ok bool
+			if constraints == nil {
+				hop, ok = edge.weight(deadline, l.weight, l.event)
+			} else if constraints.avoided[edge.target.id] {
+				continue
+			} else if edge.constrained != nil {
+				hop, ok = edge.constrained(deadline, l.weight, l.event, constraints.allowed)
+			} else {
+				hop, ok = edge.weight(deadline, l.weight, l.event)
+			}
 			if !ok {
 				// there is no suitable departure to that neighbour any more, skip it.
 				continue
 			}
-			waitTime := v.weight.Add(weight)
-			if (neighbour.weight == time.Time{} || waitTime.Before(neighbour.weight)) {
-				neighbour.weight = waitTime
-				neighbour.currentLine = line
-				neighbour.predecessor = v
-				priorityQueue.update(neighbour)
+			reached := neighbour.weight != time.Time{}
+			if !reached || hop.arrival.Before(neighbour.weight) {
+				neighbour.weight = hop.arrival
+				neighbour.event = hop.event
+				neighbour.footpath = hop.footpath
+				neighbour.departure = hop.departure
+				neighbour.predecessor = l
+				if reached {
+					priorityQueue.update(neighbour)
+				} else {
+					heap.Push(priorityQueue, neighbour)
+				}
 			}
 		}
 	}
-	result := make([]*vertex, 0, 0)
-	predecessor := t
-	for predecessor != nil {
+}
+
+// pathTo follows the predecessors of the label and returns the path ending at the label.
+func pathTo(l *label) []*label {
+	result := make([]*label, 0, 0)
+	for predecessor := l; predecessor != nil; predecessor = predecessor.predecessor {
 		result = append(result, predecessor)
-		predecessor = predecessor.predecessor
 	}
 	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
 		result[i], result[j] = result[j], result[i]
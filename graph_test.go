@@ -7,69 +7,98 @@ import (
 )
 
 func TestGraph_shortestPath(t *testing.T) {
-	a := &vertex{}
-	b := &vertex{}
-	c := &vertex{}
-	d := &vertex{}
-	e := &vertex{}
-	f := &vertex{}
-	g := &vertex{}
-	a.data = &Stop{Name: "A"}
+	a := &vertex{id: 0, data: &Stop{Name: "A"}}
+	b := &vertex{id: 1, data: &Stop{Name: "B"}}
+	c := &vertex{id: 2, data: &Stop{Name: "C"}}
+	d := &vertex{id: 3, data: &Stop{Name: "D"}}
+	e := &vertex{id: 4, data: &Stop{Name: "E"}}
+	f := &vertex{id: 5, data: &Stop{Name: "F"}}
+	g := &vertex{id: 6, data: &Stop{Name: "G"}}
 	a.neighbors = []edge{
 		{target: f, weight: constantWeight(100)},
 		{target: b, weight: constantWeight(10)},
 	}
-	b.data = &Stop{Name: "B"}
 	b.neighbors = []edge{
 		{target: e, weight: constantWeight(30)},
 		{target: d, weight: constantWeight(10)},
 	}
-	c.data = &Stop{Name: "C"}
 	c.neighbors = []edge{
 		{target: g, weight: constantWeight(40)},
 	}
-	d.data = &Stop{Name: "D"}
 	d.neighbors = []edge{
 		{target: c, weight: unsatisfiedWeight()},
 		{target: f, weight: constantWeight(45)},
 		{target: e, weight: constantWeight(10)},
 	}
-	e.data = &Stop{Name: "E"}
 	e.neighbors = []edge{
 		{target: f, weight: constantWeight(10)},
 	}
-	f.data = &Stop{Name: "F"}
 	f.neighbors = []edge{
 		{target: c, weight: constantWeight(40)},
 		{target: b, weight: constantWeight(25)},
 		{target: d, weight: constantWeight(80)},
 	}
-	g.data = &Stop{Name: "G"}
 	g.neighbors = []edge{
 		{target: f, weight: constantWeight(20)},
 	}
 	graph := graph{vertices: []*vertex{a, b, c, d, e, f, g}}
 	t.Run("success", func(t *testing.T) {
 		start, _ := time.Parse(time.RFC3339, "2020-10-11T18:00:00Z")
-		path := graph.shortestPath(a, f, start)
-		assert.Equal(t, []*vertex{a, b, d, e, f}, path, "path not computed correctly")
-		for _, v := range path[1:] {
-			assert.Equal(t, usedLine, v.currentLine, "currentLine must be set on visited vertex %s", v.data.Name)
+		path := graph.shortestPath(a, f, start, start.Add(DefaultSearchHorizon))
+		vertices := make([]*vertex, 0, len(path))
+		for _, l := range path {
+			vertices = append(vertices, l.vertex)
 		}
-		assert.Equal(t, "2020-10-11T18:40:00Z", f.weight.Format(time.RFC3339), "arrival time not computed correctly")
+		assert.Equal(t, []*vertex{a, b, d, e, f}, vertices, "path not computed correctly")
+		for _, l := range path[1:] {
+			assert.Equal(t, usedEvent, l.event, "event must be set on visited vertex %s", l.vertex.data.Name)
+			assert.Equal(t, l.predecessor.weight, l.departure, "departure must be set on visited vertex %s", l.vertex.data.Name)
+		}
+		assert.Equal(t, "2020-10-11T18:40:00Z", path[4].weight.Format(time.RFC3339), "arrival time not computed correctly")
+	})
+	t.Run("stops at target", func(t *testing.T) {
+		start, _ := time.Parse(time.RFC3339, "2020-10-11T18:00:00Z")
+		evaluated := make(map[string]bool)
+		counting := graph
+		counting.vertices = make([]*vertex, 0, len(graph.vertices))
+		for _, v := range graph.vertices {
+			copied := &vertex{id: v.id, data: v.data}
+			counting.vertices = append(counting.vertices, copied)
+		}
+		for _, v := range graph.vertices {
+			for _, e := range v.neighbors {
+				name := v.data.Name
+				weight := e.weight
+				counted := func(deadline time.Time, moment time.Time, event *Event) (hop, bool) {
+					evaluated[name] = true
+					return weight(deadline, moment, event)
+				}
+				copied := counting.vertices[v.id]
+				copied.neighbors = append(copied.neighbors, edge{target: counting.vertices[e.target.id], weight: counted})
+			}
+		}
+		path := counting.shortestPath(counting.vertices[a.id], counting.vertices[d.id], start, start.Add(DefaultSearchHorizon))
+		assert.Equal(t, "2020-10-11T18:20:00Z", path[len(path)-1].weight.Format(time.RFC3339), "arrival time not computed correctly")
+		assert.Equal(t, map[string]bool{"A": true, "B": true}, evaluated, "only the edges of vertices settled before the target may be evaluated")
+	})
+	t.Run("unreachable", func(t *testing.T) {
+		start, _ := time.Parse(time.RFC3339, "2020-10-11T18:00:00Z")
+		path := graph.shortestPath(g, a, start, start.Add(DefaultSearchHorizon))
+		assert.Equal(t, 1, len(path), "a cannot be reached from g")
+		assert.Equal(t, a, path[0].vertex, "path should only contain the target")
 	})
 }
 
-var usedLine = &Line{Id: "12 South", Name: "12 South"}
+var usedEvent = &Event{Line: &Line{Id: "12 South", Name: "12 South"}}
 
 func constantWeight(weight int) edgeWeight {
-	return func(moment time.Time, line *Line) (time.Duration, *Line, bool) {
-		return time.Duration(weight) * time.Minute, usedLine, true
+	return func(date time.Time, moment time.Time, event *Event) (hop, bool) {
+		return hop{event: usedEvent, departure: moment, arrival: moment.Add(time.Duration(weight) * time.Minute)}, true
 	}
 }
 
 func unsatisfiedWeight() edgeWeight {
-	return func(t time.Time, currentLine *Line) (time.Duration, *Line, bool) {
-		return 0 * time.Minute, nil, false
+	return func(date time.Time, t time.Time, currentEvent *Event) (hop, bool) {
+		return hop{}, false
 	}
 }
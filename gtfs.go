@@ -0,0 +1,541 @@
+package routing
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Feed contains the data of a GTFS feed converted to the data model of this package:
+// GTFS stops are converted to stops with their positions, routes are converted to lines, and the stop times
+// of a GTFS trip are converted to a trip, which creates the events of the stops. Service calendars and their exception dates are converted to services
+// of the trips. Transfers are converted to a transfer policy and footpaths.
+//
+// GTFS files and fields which are not supported are listed in Unsupported. Feeds should be
+// loaded with the LoadGTFS function.
+type Feed struct {
+	Stops       []*Stop
+	Lines       []*Line
+	Trips       []*Trip
+	Services    []*Service
+	Footpaths   []Footpath
+	Policy      *TransferPolicy
+	Unsupported []string
+	stops       map[string]*Stop
+}
+
+// Timetable creates a new timetable from the feed. Additional options are applied after the
+// transfer policy and the footpaths of the feed.
+func (f *Feed) Timetable(options ...Option) Timetable {
+	options = append([]Option{WithTransferPolicy(f.Policy), WithFootpaths(f.Footpaths...)}, options...)
+	return NewTimetable(f.Stops, options...)
+}
+
+// Stop returns the stop with the given GTFS stop_id or nil if there is no such stop.
+func (f *Feed) Stop(id string) *Stop {
+	return f.stops[id]
+}
+
+var gtfsFields = map[string][]string{
+	"agency.txt":         {},
+	"stops.txt":          {"stop_id", "stop_name", "stop_lat", "stop_lon"},
+	"routes.txt":         {"route_id", "route_short_name", "route_long_name"},
+	"trips.txt":          {"route_id", "service_id", "trip_id"},
+	"stop_times.txt":     {"trip_id", "arrival_time", "departure_time", "stop_id", "stop_sequence"},
+	"calendar.txt":       {"service_id", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday", "start_date", "end_date"},
+	"calendar_dates.txt": {"service_id", "date", "exception_type"},
+	"transfers.txt":      {"from_stop_id", "to_stop_id", "from_route_id", "to_route_id", "from_trip_id", "to_trip_id", "transfer_type", "min_transfer_time"},
+}
+
+// LoadGTFS reads the GTFS feed at the given path, which can either be a zip file or a directory.
+// The files stops.txt, routes.txt, trips.txt, and stop_times.txt are required, calendar.txt, calendar_dates.txt,
+// and transfers.txt are optional. Trips without service_id run every day.
+// If the feed cannot be read or is malformed, then an error wrapping ErrInvalidFeed is returned.
+func LoadGTFS(path string) (*Feed, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return loadGTFSDirectory(path)
+	}
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open zip file: %v: %w", err, ErrInvalidFeed)
+	}
+	defer func() { _ = reader.Close() }()
+	files := make(map[string]func() (io.ReadCloser, error))
+	for _, file := range reader.File {
+		files[filepath.Base(file.Name)] = file.Open
+	}
+	return loadGTFS(files)
+}
+
+func loadGTFSDirectory(path string) (*Feed, error) {
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]func() (io.ReadCloser, error))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := filepath.Join(path, entry.Name())
+		files[entry.Name()] = func() (io.ReadCloser, error) {
+			return os.Open(name)
+		}
+	}
+	return loadGTFS(files)
+}
+
+type gtfsLoader struct {
+	feed     *Feed
+	files    map[string]func() (io.ReadCloser, error)
+	trips    map[string]*Trip
+	routes   map[string]*Line
+	services map[string]*Service
+}
+
+func loadGTFS(files map[string]func() (io.ReadCloser, error)) (*Feed, error) {
+	loader := &gtfsLoader{
+		feed:     &Feed{Policy: NewTransferPolicy(DefaultTransferTime), stops: make(map[string]*Stop)},
+		files:    files,
+		trips:    make(map[string]*Trip),
+		routes:   make(map[string]*Line),
+		services: make(map[string]*Service),
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := gtfsFields[name]; !ok && strings.HasSuffix(name, ".txt") {
+			loader.unsupported("file \"%s\" is not supported", name)
+		}
+	}
+	steps := []func() error{loader.loadStops, loader.loadRoutes, loader.loadCalendars, loader.loadTrips, loader.loadTransfers}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return nil, err
+		}
+	}
+	return loader.feed, nil
+}
+
+func (g *gtfsLoader) unsupported(format string, args ...interface{}) {
+	g.feed.Unsupported = append(g.feed.Unsupported, fmt.Sprintf(format, args...))
+}
+
+// gtfsTable is the content of a GTFS file. The fields of a record can be accessed by their names.
+type gtfsTable struct {
+	name    string
+	header  map[string]int
+	records [][]string
+}
+
+func (t *gtfsTable) get(record []string, field string) string {
+	index, ok := t.header[field]
+	if !ok || index >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[index])
+}
+
+func (t *gtfsTable) require(fields ...string) error {
+	for _, field := range fields {
+		if _, ok := t.header[field]; !ok {
+			return fmt.Errorf("%s: required field \"%s\" is missing: %w", t.name, field, ErrInvalidFeed)
+		}
+	}
+	return nil
+}
+
+// read reads the given GTFS file. If the file does not exist and is not required, then nil is returned.
+func (g *gtfsLoader) read(name string, required bool) (*gtfsTable, error) {
+	open, ok := g.files[name]
+	if !ok {
+		if required {
+			return nil, fmt.Errorf("required file \"%s\" is missing: %w", name, ErrInvalidFeed)
+		}
+		return nil, nil
+	}
+	file, err := open()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v: %w", name, err, ErrInvalidFeed)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s: the header is missing: %w", name, ErrInvalidFeed)
+	}
+	table := &gtfsTable{name: name, header: make(map[string]int), records: records[1:]}
+	supported := make(map[string]bool)
+	for _, field := range gtfsFields[name] {
+		supported[field] = true
+	}
+	for i, field := range records[0] {
+		field = strings.TrimSpace(strings.TrimPrefix(field, "\ufeff"))
+		table.header[field] = i
+		if !supported[field] && len(gtfsFields[name]) != 0 {
+			g.unsupported("%s: field \"%s\" is not supported", name, field)
+		}
+	}
+	return table, nil
+}
+
+func (g *gtfsLoader) loadStops() error {
+	table, err := g.read("stops.txt", true)
+	if err != nil {
+		return err
+	}
+	if err := table.require("stop_id"); err != nil {
+		return err
+	}
+	for _, record := range table.records {
+		stop := NewStop(table.get(record, "stop_id"), table.get(record, "stop_name"))
+		if _, ok := g.feed.stops[stop.Id]; ok {
+			return fmt.Errorf("stops.txt: duplicate stop_id \"%s\": %w", stop.Id, ErrInvalidFeed)
+		}
+		position, err := parseGTFSCoordinate(table.get(record, "stop_lat"), table.get(record, "stop_lon"))
+		if err != nil {
+			return fmt.Errorf("stops.txt: stop \"%s\": %v: %w", stop.Id, err, ErrInvalidFeed)
+		}
+		stop.Position = position
+		g.feed.stops[stop.Id] = stop
+		g.feed.Stops = append(g.feed.Stops, stop)
+	}
+	return nil
+}
+
+// parseGTFSCoordinate parses the latitude and longitude of a stop. If both are empty, then nil is returned.
+func parseGTFSCoordinate(lat string, lon string) (*Coordinate, error) {
+	if lat == "" && lon == "" {
+		return nil, nil
+	}
+	latitude, err := strconv.ParseFloat(lat, 64)
+	if err != nil || latitude < -90 || latitude > 90 {
+		return nil, fmt.Errorf("the latitude \"%s\" is invalid", lat)
+	}
+	longitude, err := strconv.ParseFloat(lon, 64)
+	if err != nil || longitude < -180 || longitude > 180 {
+		return nil, fmt.Errorf("the longitude \"%s\" is invalid", lon)
+	}
+	return &Coordinate{Lat: latitude, Lon: longitude}, nil
+}
+
+func (g *gtfsLoader) loadRoutes() error {
+	table, err := g.read("routes.txt", true)
+	if err != nil {
+		return err
+	}
+	if err := table.require("route_id"); err != nil {
+		return err
+	}
+	for _, record := range table.records {
+		name := table.get(record, "route_short_name")
+		if name == "" {
+			name = table.get(record, "route_long_name")
+		}
+		line := &Line{Id: table.get(record, "route_id"), Name: name}
+		if _, ok := g.routes[line.Id]; ok {
+			return fmt.Errorf("routes.txt: duplicate route_id \"%s\": %w", line.Id, ErrInvalidFeed)
+		}
+		g.routes[line.Id] = line
+		g.feed.Lines = append(g.feed.Lines, line)
+	}
+	return nil
+}
+
+type gtfsStopTime struct {
+	sequence int
+	stopTime StopTime
+}
+
+func (g *gtfsLoader) loadTrips() error {
+	trips, err := g.read("trips.txt", true)
+	if err != nil {
+		return err
+	}
+	if err := trips.require("route_id", "trip_id"); err != nil {
+		return err
+	}
+	stopTimes, err := g.read("stop_times.txt", true)
+	if err != nil {
+		return err
+	}
+	if err := stopTimes.require("trip_id", "arrival_time", "departure_time", "stop_id", "stop_sequence"); err != nil {
+		return err
+	}
+	grouped := make(map[string][]gtfsStopTime)
+	for i, record := range stopTimes.records {
+		stopTime, sequence, err := g.parseStopTime(stopTimes, record)
+		if err != nil {
+			return fmt.Errorf("stop_times.txt: record %d: %v: %w", i+1, err, ErrInvalidFeed)
+		}
+		tripId := stopTimes.get(record, "trip_id")
+		grouped[tripId] = append(grouped[tripId], gtfsStopTime{sequence: sequence, stopTime: stopTime})
+	}
+	for _, record := range trips.records {
+		id := trips.get(record, "trip_id")
+		line, ok := g.routes[trips.get(record, "route_id")]
+		if !ok {
+			return fmt.Errorf("trips.txt: trip \"%s\" references unknown route \"%s\": %w", id, trips.get(record, "route_id"), ErrInvalidFeed)
+		}
+		if _, ok := g.trips[id]; ok {
+			return fmt.Errorf("trips.txt: duplicate trip_id \"%s\": %w", id, ErrInvalidFeed)
+		}
+		var service *Service
+		if serviceId := trips.get(record, "service_id"); serviceId != "" {
+			service, ok = g.services[serviceId]
+			if !ok {
+				return fmt.Errorf("trips.txt: trip \"%s\" references unknown service \"%s\": %w", id, serviceId, ErrInvalidFeed)
+			}
+		}
+		times := grouped[id]
+		sort.Slice(times, func(i, j int) bool {
+			return times[i].sequence < times[j].sequence
+		})
+		ordered := make([]StopTime, 0, len(times))
+		for _, stopTime := range times {
+			ordered = append(ordered, stopTime.stopTime)
+		}
+		trip, err := NewTrip(id, line, ordered)
+		if err != nil {
+			return fmt.Errorf("trips.txt: %v: %w", err, ErrInvalidFeed)
+		}
+		trip.Service = service
+		g.trips[id] = trip
+		g.feed.Trips = append(g.feed.Trips, trip)
+	}
+	return nil
+}
+
+func (g *gtfsLoader) parseStopTime(table *gtfsTable, record []string) (StopTime, int, error) {
+	stop, ok := g.feed.stops[table.get(record, "stop_id")]
+	if !ok {
+		return StopTime{}, 0, fmt.Errorf("unknown stop \"%s\"", table.get(record, "stop_id"))
+	}
+	sequence, err := strconv.Atoi(table.get(record, "stop_sequence"))
+	if err != nil {
+		return StopTime{}, 0, fmt.Errorf("invalid stop_sequence \"%s\"", table.get(record, "stop_sequence"))
+	}
+	arrivalField := table.get(record, "arrival_time")
+	departureField := table.get(record, "departure_time")
+	if arrivalField == "" && departureField == "" {
+		return StopTime{}, 0, fmt.Errorf("stop times without arrival_time and departure_time are not supported")
+	}
+	if arrivalField == "" {
+		arrivalField = departureField
+	}
+	if departureField == "" {
+		departureField = arrivalField
+	}
+	arrival, err := parseGTFSTime(arrivalField)
+	if err != nil {
+		return StopTime{}, 0, err
+	}
+	departure, err := parseGTFSTime(departureField)
+	if err != nil {
+		return StopTime{}, 0, err
+	}
+	return StopTime{Stop: stop, Arrival: arrival, Departure: departure}, sequence, nil
+}
+
+// parseGTFSTime converts a GTFS time (HH:MM:SS) into a Time. Because times are given in minutes,
+// the seconds are truncated.
+func parseGTFSTime(value string) (Time, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("the string \"%s\" is invalid: %w", value, ErrInvalidTime)
+	}
+	numbers := make([]int, 0, 3)
+	for _, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return "", fmt.Errorf("the string \"%s\" is invalid: %w", value, ErrInvalidTime)
+		}
+		numbers = append(numbers, number)
+	}
+	if numbers[1] > 59 || numbers[2] > 59 {
+		return "", fmt.Errorf("the string \"%s\" is invalid: %w", value, ErrInvalidTime)
+	}
+	return CreateTime(numbers[0], numbers[1]), nil
+}
+
+func (g *gtfsLoader) loadTransfers() error {
+	table, err := g.read("transfers.txt", false)
+	if table == nil || err != nil {
+		return err
+	}
+	for i, record := range table.records {
+		if err := g.loadTransfer(table, record); err != nil {
+			return fmt.Errorf("transfers.txt: record %d: %v: %w", i+1, err, ErrInvalidFeed)
+		}
+	}
+	return nil
+}
+
+func (g *gtfsLoader) loadTransfer(table *gtfsTable, record []string) error {
+	transferType := table.get(record, "transfer_type")
+	if transferType == "" {
+		transferType = "0"
+	}
+	from, to := g.feed.stops[table.get(record, "from_stop_id")], g.feed.stops[table.get(record, "to_stop_id")]
+	fromRoute, toRoute := g.routes[table.get(record, "from_route_id")], g.routes[table.get(record, "to_route_id")]
+	fromTrip, toTrip := g.trips[table.get(record, "from_trip_id")], g.trips[table.get(record, "to_trip_id")]
+	switch transferType {
+	case "0":
+		// recommended transfer point, there is nothing to do
+		return nil
+	case "1", "2":
+		transferTime := 0 * time.Minute
+		if transferType == "2" {
+			seconds, err := strconv.Atoi(table.get(record, "min_transfer_time"))
+			if err != nil {
+				return fmt.Errorf("invalid min_transfer_time \"%s\"", table.get(record, "min_transfer_time"))
+			}
+			transferTime = time.Duration(seconds) * time.Second
+		}
+		if from == nil || to == nil {
+			return fmt.Errorf("unknown stop \"%s\" or \"%s\"", table.get(record, "from_stop_id"), table.get(record, "to_stop_id"))
+		}
+		if fromRoute != nil && toRoute != nil && from == to {
+			g.feed.Policy.SetStopLineTransferTime(from, fromRoute, toRoute, transferTime)
+			return nil
+		}
+		if table.get(record, "from_trip_id") != "" || table.get(record, "to_trip_id") != "" {
+			g.unsupported("transfers.txt: transfer from trip \"%s\" at stop \"%s\" to trip \"%s\" at stop \"%s\" is not supported",
+				table.get(record, "from_trip_id"), from.Id, table.get(record, "to_trip_id"), to.Id)
+			return nil
+		}
+		if table.get(record, "from_route_id") != "" || table.get(record, "to_route_id") != "" {
+			g.unsupported("transfers.txt: transfer from route \"%s\" at stop \"%s\" to route \"%s\" at stop \"%s\" is not supported",
+				table.get(record, "from_route_id"), from.Id, table.get(record, "to_route_id"), to.Id)
+			return nil
+		}
+		if from == to {
+			g.feed.Policy.SetStopTransferTime(from, transferTime)
+			return nil
+		}
+		g.feed.Footpaths = append(g.feed.Footpaths, Footpath{From: from, To: to, Duration: transferTime})
+		return nil
+	case "4":
+		if fromTrip == nil || toTrip == nil {
+			return fmt.Errorf("unknown trip \"%s\" or \"%s\"", table.get(record, "from_trip_id"), table.get(record, "to_trip_id"))
+		}
+		g.feed.Policy.SetTripStaySeated(fromTrip, toTrip)
+		return nil
+	}
+	g.unsupported("transfers.txt: transfer_type \"%s\" is not supported", transferType)
+	return nil
+}
+
+// gtfsWeekdays contains the weekday fields of calendar.txt indexed by time.Weekday.
+var gtfsWeekdays = []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+func (g *gtfsLoader) loadCalendars() error {
+	calendar, err := g.read("calendar.txt", false)
+	if err != nil {
+		return err
+	}
+	if calendar != nil {
+		if err := calendar.require(append([]string{"service_id", "start_date", "end_date"}, gtfsWeekdays...)...); err != nil {
+			return err
+		}
+		for i, record := range calendar.records {
+			if err := g.loadCalendar(calendar, record); err != nil {
+				return fmt.Errorf("calendar.txt: record %d: %v: %w", i+1, err, ErrInvalidFeed)
+			}
+		}
+	}
+	dates, err := g.read("calendar_dates.txt", false)
+	if err != nil || dates == nil {
+		return err
+	}
+	if err := dates.require("service_id", "date", "exception_type"); err != nil {
+		return err
+	}
+	for i, record := range dates.records {
+		if err := g.loadCalendarDate(dates, record); err != nil {
+			return fmt.Errorf("calendar_dates.txt: record %d: %v: %w", i+1, err, ErrInvalidFeed)
+		}
+	}
+	return nil
+}
+
+func (g *gtfsLoader) loadCalendar(table *gtfsTable, record []string) error {
+	id := table.get(record, "service_id")
+	if _, ok := g.services[id]; ok {
+		return fmt.Errorf("duplicate service_id \"%s\"", id)
+	}
+	service := &Service{Id: id}
+	for weekday, field := range gtfsWeekdays {
+		switch table.get(record, field) {
+		case "1":
+			service.Weekdays[weekday] = true
+		case "0":
+		default:
+			return fmt.Errorf("%s \"%s\" is neither 0 nor 1", field, table.get(record, field))
+		}
+	}
+	var err error
+	if service.Start, err = parseGTFSDate(table.get(record, "start_date")); err != nil {
+		return err
+	}
+	if service.End, err = parseGTFSDate(table.get(record, "end_date")); err != nil {
+		return err
+	}
+	g.addService(service)
+	return nil
+}
+
+func (g *gtfsLoader) loadCalendarDate(table *gtfsTable, record []string) error {
+	id := table.get(record, "service_id")
+	service, ok := g.services[id]
+	if !ok {
+		service = &Service{Id: id}
+		g.addService(service)
+	}
+	date, err := parseGTFSDate(table.get(record, "date"))
+	if err != nil {
+		return err
+	}
+	switch exceptionType := table.get(record, "exception_type"); exceptionType {
+	case "1":
+		service.Added = append(service.Added, date)
+	case "2":
+		service.Removed = append(service.Removed, date)
+	default:
+		return fmt.Errorf("exception_type \"%s\" is invalid", exceptionType)
+	}
+	return nil
+}
+
+func (g *gtfsLoader) addService(service *Service) {
+	g.services[service.Id] = service
+	g.feed.Services = append(g.feed.Services, service)
+}
+
+// parseGTFSDate parses a date in the GTFS format YYYYMMDD.
+func parseGTFSDate(value string) (time.Time, error) {
+	date, err := time.Parse("20060102", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("the date \"%s\" is invalid", value)
+	}
+	return date, nil
+}
@@ -0,0 +1,283 @@
+package routing
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const gtfsAgency = "routing"
+const gtfsService = "ALWAYS"
+
+// ExportGTFS writes the timetable as GTFS feed into the given directory, which is created if it does not exist.
+// See WriteGTFS for details.
+func (t *Timetable) ExportGTFS(directory string) error {
+	files, err := t.gtfsFiles()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return err
+	}
+	for _, name := range sortedFileNames(files) {
+		file, err := os.Create(filepath.Join(directory, name))
+		if err != nil {
+			return err
+		}
+		err = writeCsv(file, files[name])
+		closeErr := file.Close()
+		if err != nil {
+			return err
+		}
+		if closeErr != nil {
+			return closeErr
+		}
+	}
+	return nil
+}
+
+// WriteGTFS writes the timetable as zipped GTFS feed to the writer. Events that reference a trip are
+// exported with the stop times of their trip. An error is returned if such an event has a service other than
+// its trip, because GTFS does not know services of single stop times. All other events are chained to trips:
+// an event is continued by the first event of the same line and service departing at its next stop after the
+// vehicle's arrival. Note that events without trip of the same line count as the same vehicle, thus changing
+// between them needs no transfer time. In the exported feed, they belong to different trips and changing
+// between them needs the transfer time, so queries on the loaded feed may find later connections.
+//
+// Lines are exported as routes of the type bus. The services of the trips are exported as calendars with exception dates,
+// trips without service are exported with a service running every day. The transfer policy is exported
+// as transfers; because GTFS does not know default transfer times, a transfer time is written for every stop if the
+// default transfer time of the policy is not DefaultTransferTime. Transfer times between lines are written for every
+// stop at which the transfer is possible. Lines marked as "stay seated" are exported the same way with a transfer time
+// of zero, only pairs of trips marked as "stay seated" are exported as in-seat transfers. Footpaths are exported as
+// transfers between different stops.
+//
+// GTFS requires the coordinates of every stop. Stops without position are exported with empty coordinates, thus the
+// feed is not valid GTFS if the timetable contains such stops. LoadGTFS accepts those feeds, other consumers may not.
+func (t *Timetable) WriteGTFS(writer io.Writer) error {
+	files, err := t.gtfsFiles()
+	if err != nil {
+		return err
+	}
+	archive := zip.NewWriter(writer)
+	for _, name := range sortedFileNames(files) {
+		file, err := archive.Create(name)
+		if err != nil {
+			return err
+		}
+		if err := writeCsv(file, files[name]); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+func sortedFileNames(files map[string][][]string) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func writeCsv(writer io.Writer, records [][]string) error {
+	csvWriter := csv.NewWriter(writer)
+	if err := csvWriter.WriteAll(records); err != nil {
+		return err
+	}
+	return csvWriter.Error()
+}
+
+func (t *Timetable) gtfsFiles() (map[string][][]string, error) {
+	if t.invalid != nil {
+		return nil, t.invalid
+	}
+	trips, err := t.trips()
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][][]string{
+		"agency.txt":         {{"agency_id", "agency_name", "agency_url", "agency_timezone"}, {gtfsAgency, "Simple Timetable Routing", "https://github.com/fafeitsch/simple-timetable-routing", "Etc/UTC"}},
+		"stops.txt":          {{"stop_id", "stop_name", "stop_lat", "stop_lon"}},
+		"routes.txt":         {{"route_id", "agency_id", "route_short_name", "route_type"}},
+		"trips.txt":          {{"route_id", "service_id", "trip_id"}},
+		"stop_times.txt":     {{"trip_id", "arrival_time", "departure_time", "stop_id", "stop_sequence"}},
+		"calendar.txt":       {{"service_id", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday", "start_date", "end_date"}},
+		"calendar_dates.txt": {{"service_id", "date", "exception_type"}},
+		"transfers.txt":      {{"from_stop_id", "to_stop_id", "from_route_id", "to_route_id", "from_trip_id", "to_trip_id", "transfer_type", "min_transfer_time"}},
+	}
+	for _, vertex := range t.graph.vertices {
+		lat, lon := "", ""
+		if position := vertex.data.Position; position != nil {
+			lat = strconv.FormatFloat(position.Lat, 'f', -1, 64)
+			lon = strconv.FormatFloat(position.Lon, 'f', -1, 64)
+		}
+		files["stops.txt"] = append(files["stops.txt"], []string{vertex.data.Id, vertex.data.Name, lat, lon})
+	}
+	lines := make(map[*Line]bool)
+	services := make(map[*Service]bool)
+	for _, trip := range trips {
+		if trip.Line == nil {
+			return nil, fmt.Errorf("trip \"%s\" does not belong to a line", trip.Id)
+		}
+		if !lines[trip.Line] {
+			lines[trip.Line] = true
+			files["routes.txt"] = append(files["routes.txt"], []string{trip.Line.Id, gtfsAgency, trip.Line.Name, "3"})
+		}
+		if !services[trip.Service] {
+			services[trip.Service] = true
+			files["calendar.txt"] = append(files["calendar.txt"], gtfsCalendar(trip.Service))
+			files["calendar_dates.txt"] = append(files["calendar_dates.txt"], gtfsCalendarDates(trip.Service)...)
+		}
+		serviceId := gtfsService
+		if trip.Service != nil {
+			serviceId = trip.Service.Id
+		}
+		files["trips.txt"] = append(files["trips.txt"], []string{trip.Line.Id, serviceId, trip.Id})
+		for i, stopTime := range trip.StopTimes {
+			arrival, err := formatGTFSTime(stopTime.Arrival)
+			if err != nil {
+				return nil, fmt.Errorf("arrival of trip \"%s\": %w", trip.Id, err)
+			}
+			departure, err := formatGTFSTime(stopTime.Departure)
+			if err != nil {
+				return nil, fmt.Errorf("departure of trip \"%s\": %w", trip.Id, err)
+			}
+			record := []string{trip.Id, arrival, departure, stopTime.Stop.Id, strconv.Itoa(i + 1)}
+			files["stop_times.txt"] = append(files["stop_times.txt"], record)
+		}
+	}
+	files["transfers.txt"] = append(files["transfers.txt"], t.gtfsTransfers(trips)...)
+	return files, nil
+}
+
+func (t *Timetable) gtfsTransfers(trips []*Trip) [][]string {
+	result := make([][]string, 0, 0)
+	seconds := func(duration time.Duration) string {
+		return strconv.Itoa(int(duration / time.Second))
+	}
+	for _, vertex := range t.graph.vertices {
+		stop := vertex.data
+		transferTime, ok := t.policy.stops[stop.Id]
+		if !ok && t.policy.Default != DefaultTransferTime {
+			transferTime, ok = t.policy.Default, true
+		}
+		if ok {
+			result = append(result, []string{stop.Id, stop.Id, "", "", "", "", "2", seconds(transferTime)})
+		}
+	}
+	// the stops at which the vehicles of a line arrive and depart, respectively
+	arrivals := make(map[*Line]map[*Stop]bool)
+	departures := make(map[*Line]map[*Stop]bool)
+	for _, vertex := range t.graph.vertices {
+		for _, event := range vertex.data.Events {
+			if !t.contains(event.NextStop) {
+				continue
+			}
+			if arrivals[event.Line] == nil {
+				arrivals[event.Line] = make(map[*Stop]bool)
+				departures[event.Line] = make(map[*Stop]bool)
+			}
+			departures[event.Line][vertex.data] = true
+			arrivals[event.Line][event.NextStop] = true
+		}
+	}
+	// GTFS requires a stop for transfers between routes, thus a record is written for every stop at which the transfer is possible
+	linePairs := make([][]string, 0, 0)
+	addLinePair := func(pair linePair, transferTime time.Duration) {
+		for _, vertex := range t.graph.vertices {
+			stop := vertex.data
+			_, overridden := t.policy.stopLines[stopLinePair{stop: stop.Id, linePair: pair}]
+			if arrivals[pair.from][stop] && departures[pair.to][stop] && (!overridden || t.policy.staySeated[pair]) {
+				linePairs = append(linePairs, []string{stop.Id, stop.Id, pair.from.Id, pair.to.Id, "", "", "2", seconds(transferTime)})
+			}
+		}
+	}
+	for pair, transferTime := range t.policy.lines {
+		if !t.policy.staySeated[pair] {
+			addLinePair(pair, transferTime)
+		}
+	}
+	for pair, transferTime := range t.policy.stopLines {
+		if _, ok := t.stops[pair.stop]; ok && !t.policy.staySeated[pair.linePair] {
+			linePairs = append(linePairs, []string{pair.stop, pair.stop, pair.from.Id, pair.to.Id, "", "", "2", seconds(transferTime)})
+		}
+	}
+	// a line marked as "stay seated" cannot be expressed in GTFS, but it is the same as a transfer time of zero
+	for pair := range t.policy.staySeated {
+		addLinePair(pair, 0*time.Minute)
+	}
+	exported := make(map[*Trip]bool)
+	for _, trip := range trips {
+		exported[trip] = true
+	}
+	for pair := range t.policy.seatedTrips {
+		if exported[pair.from] && exported[pair.to] {
+			linePairs = append(linePairs, []string{"", "", "", "", pair.from.Id, pair.to.Id, "4", ""})
+		}
+	}
+	sort.Slice(linePairs, func(i, j int) bool {
+		return fmt.Sprint(linePairs[i]) < fmt.Sprint(linePairs[j])
+	})
+	result = append(result, linePairs...)
+	for _, footpath := range t.footpaths {
+		if t.contains(footpath.From) && t.contains(footpath.To) {
+			result = append(result, []string{footpath.From.Id, footpath.To.Id, "", "", "", "", "2", seconds(footpath.Duration)})
+		}
+	}
+	return result
+}
+
+// gtfsCalendar converts the service to a record of calendar.txt. A nil service runs every day.
+func gtfsCalendar(service *Service) []string {
+	if service == nil {
+		return []string{gtfsService, "1", "1", "1", "1", "1", "1", "1", "19700101", "20991231"}
+	}
+	record := []string{service.Id}
+	for _, weekday := range []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday} {
+		if service.Weekdays[weekday] {
+			record = append(record, "1")
+		} else {
+			record = append(record, "0")
+		}
+	}
+	start, end := "19700101", "20991231"
+	if !service.Start.IsZero() {
+		start = service.Start.Format("20060102")
+	}
+	if !service.End.IsZero() {
+		end = service.End.Format("20060102")
+	}
+	return append(record, start, end)
+}
+
+// gtfsCalendarDates converts the exception dates of the service to records of calendar_dates.txt.
+func gtfsCalendarDates(service *Service) [][]string {
+	result := make([][]string, 0, 0)
+	if service == nil {
+		return result
+	}
+	for _, date := range service.Added {
+		result = append(result, []string{service.Id, date.Format("20060102"), "1"})
+	}
+	for _, date := range service.Removed {
+		result = append(result, []string{service.Id, date.Format("20060102"), "2"})
+	}
+	return result
+}
+
+// formatGTFSTime converts a time to the GTFS format HH:MM:SS.
+func formatGTFSTime(value Time) (string, error) {
+	hour, minute, err := value.parse()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%02d:%02d:00", hour, minute), nil
+}
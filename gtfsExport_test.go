@@ -0,0 +1,195 @@
+package routing
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTimetable_ExportGTFS(t *testing.T) {
+	directory, err := ioutil.TempDir("", "gtfs")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(directory) }()
+
+	t.Run("round trip", func(t *testing.T) {
+		network := createTestNetwork()
+		policy := NewTransferPolicy(4 * time.Minute)
+		policy.SetStopTransferTime(network.mainStation, 6*time.Minute)
+		policy.SetLineTransferTime(network.blueLine, network.redLine, 1*time.Minute)
+		policy.SetStaySeated(network.redLine, network.blueLine)
+		footpaths := WithFootpaths(
+			Footpath{From: network.docksAE, To: network.docksFG, Duration: 4 * time.Minute},
+			Footpath{From: network.docksFG, To: network.docksAE, Duration: 4 * time.Minute},
+		)
+		weekdays := NewService("WD", time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday)
+		weekdays.End = date("00:00").AddDate(0, 1, 0)
+		weekdays.Removed = []time.Time{date("00:00")}
+		for _, stop := range network.stops() {
+			for i := range stop.Events {
+				if stop.Events[i].Line == network.redLine {
+					stop.Events[i].Service = weekdays
+				}
+			}
+		}
+		network.mainStation.Position = &Coordinate{Lat: 49.8017, Lon: 9.9359}
+		network.chalet.Position = &Coordinate{Lat: -33.4489, Lon: -70.6693}
+		original := NewTimetable(network.stops(), WithTransferPolicy(policy), footpaths)
+
+		path := filepath.Join(directory, "round-trip")
+		require.NoError(t, original.ExportGTFS(path))
+		feed, err := LoadGTFS(path)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"routes.txt: field \"agency_id\" is not supported",
+			"routes.txt: field \"route_type\" is not supported",
+		}, feed.Unsupported, "unsupported fields are wrong")
+		imported := feed.Timetable()
+		assertSameQueryResults(t, &original, network.stops(), &imported, feed)
+		assert.Equal(t, network.mainStation.Position, feed.Stop("MS").Position, "position of main station is wrong")
+		assert.Equal(t, network.chalet.Position, feed.Stop("CH").Position, "position of chalet is wrong")
+		assert.Nil(t, feed.Stop("AR").Position, "position of airport should be unknown")
+
+		exported := filepath.Join(directory, "exported")
+		require.NoError(t, imported.ExportGTFS(exported))
+		reimportedFeed, err := LoadGTFS(exported)
+		require.NoError(t, err)
+		reimported := reimportedFeed.Timetable()
+		assertSameQueryResults(t, &imported, feed.Stops, &reimported, reimportedFeed)
+	})
+	t.Run("import, export, import", func(t *testing.T) {
+		feed, err := LoadGTFS(filepath.Join("testdata", "gtfs"))
+		require.NoError(t, err)
+		original := feed.Timetable()
+		path := filepath.Join(directory, "feed")
+		require.NoError(t, original.ExportGTFS(path))
+		reimportedFeed, err := LoadGTFS(path)
+		require.NoError(t, err)
+		reimported := reimportedFeed.Timetable()
+		assertSameQueryResults(t, &original, feed.Stops, &reimported, reimportedFeed)
+	})
+	t.Run("reconstructed trips", func(t *testing.T) {
+		zoo := NewStop("ZO", "Zoo")
+		mall := NewStop("MA", "Mall")
+		court := NewStop("CO", "Court")
+		southBound := &Line{Name: "1 SouthBound", Id: "1"}
+		zoo.Events = []Event{
+			{Departure: "8:30", Line: southBound, NextStop: mall, TravelTime: 4 * time.Minute},
+			{Departure: "8:00", Line: southBound, NextStop: mall, TravelTime: 4 * time.Minute},
+		}
+		mall.Events = []Event{
+			{Departure: "8:05", Line: southBound, NextStop: court, TravelTime: 9 * time.Minute},
+			{Departure: "8:35", Line: southBound, NextStop: court, TravelTime: 9 * time.Minute},
+		}
+		timetable := NewTimetable([]*Stop{zoo, mall, court})
+		path := filepath.Join(directory, "reconstructed")
+		require.NoError(t, timetable.ExportGTFS(path))
+		stopTimes, err := ioutil.ReadFile(filepath.Join(path, "stop_times.txt"))
+		require.NoError(t, err)
+		expected := "trip_id,arrival_time,departure_time,stop_id,stop_sequence\n" +
+			"1-1,08:30:00,08:30:00,ZO,1\n" +
+			"1-1,08:34:00,08:35:00,MA,2\n" +
+			"1-1,08:44:00,08:44:00,CO,3\n" +
+			"1-2,08:00:00,08:00:00,ZO,1\n" +
+			"1-2,08:04:00,08:05:00,MA,2\n" +
+			"1-2,08:14:00,08:14:00,CO,3\n"
+		assert.Equal(t, expected, string(stopTimes), "stop times are wrong")
+	})
+	t.Run("transfers", func(t *testing.T) {
+		zoo := NewStop("ZO", "Zoo")
+		mall := NewStop("MA", "Mall")
+		court := NewStop("CO", "Court")
+		southBound := &Line{Name: "1 SouthBound", Id: "1"}
+		harbour := &Line{Name: "2 Harbour", Id: "2"}
+		toMall, err := NewTrip("1-1", southBound, []StopTime{{Stop: zoo, Arrival: "8:00", Departure: "8:00"}, {Stop: mall, Arrival: "8:04", Departure: "8:04"}})
+		require.NoError(t, err)
+		continued, err := NewTrip("2-1", harbour, []StopTime{{Stop: mall, Arrival: "8:05", Departure: "8:05"}, {Stop: court, Arrival: "8:14", Departure: "8:14"}})
+		require.NoError(t, err)
+		_, err = NewTrip("2-2", harbour, []StopTime{{Stop: mall, Arrival: "8:35", Departure: "8:35"}, {Stop: court, Arrival: "8:44", Departure: "8:44"}})
+		require.NoError(t, err)
+		policy := NewTransferPolicy(DefaultTransferTime)
+		policy.SetLineTransferTime(southBound, harbour, 2*time.Minute)
+		policy.SetTripStaySeated(toMall, continued)
+		policy.SetStopLineTransferTime(mall, southBound, harbour, 3*time.Minute)
+		policy.SetStopLineTransferTime(court, southBound, harbour, 1*time.Minute)
+		timetable := NewTimetable([]*Stop{zoo, mall, court}, WithTransferPolicy(policy))
+		path := filepath.Join(directory, "transfers")
+		require.NoError(t, timetable.ExportGTFS(path))
+		transfers, err := ioutil.ReadFile(filepath.Join(path, "transfers.txt"))
+		require.NoError(t, err)
+		expected := "from_stop_id,to_stop_id,from_route_id,to_route_id,from_trip_id,to_trip_id,transfer_type,min_transfer_time\n" +
+			",,,,1-1,2-1,4,\n" +
+			"CO,CO,1,2,,,2,60\n" +
+			"MA,MA,1,2,,,2,180\n"
+		assert.Equal(t, expected, string(transfers), "transfers are wrong")
+	})
+	t.Run("service of event", func(t *testing.T) {
+		zoo := NewStop("ZO", "Zoo")
+		mall := NewStop("MA", "Mall")
+		trip, err := NewTrip("1-1", &Line{Id: "1"}, []StopTime{{Stop: zoo, Arrival: "8:30", Departure: "8:30"}, {Stop: mall, Arrival: "8:34", Departure: "8:34"}})
+		require.NoError(t, err)
+		zoo.Events[0].Service = NewService("weekend", time.Saturday, time.Sunday)
+		timetable := NewTimetable([]*Stop{zoo, mall})
+		err = timetable.ExportGTFS(filepath.Join(directory, "service"))
+		assert.EqualError(t, err, "event at stop \"ZO\" of trip \"1-1\" has a service other than its trip", "error is wrong")
+
+		trip.Service = zoo.Events[0].Service
+		assert.NoError(t, timetable.ExportGTFS(filepath.Join(directory, "service")), "service of the trip can be exported")
+	})
+	t.Run("invalid departure", func(t *testing.T) {
+		zoo := NewStop("ZO", "Zoo")
+		mall := NewStop("MA", "Mall")
+		zoo.Events = []Event{{Departure: "8.30", Line: &Line{Id: "1"}, NextStop: mall, TravelTime: 4 * time.Minute}}
+		timetable := NewTimetable([]*Stop{zoo, mall})
+		err := timetable.ExportGTFS(filepath.Join(directory, "invalid"))
+		assert.EqualError(t, err, "departure at stop \"ZO\": the string \"8.30\" is invalid: time does not match the required format", "error is wrong")
+	})
+}
+
+func TestTimetable_WriteGTFS(t *testing.T) {
+	directory, err := ioutil.TempDir("", "gtfs")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(directory) }()
+
+	network := createTestNetwork()
+	original := NewTimetable(network.stops())
+	path := filepath.Join(directory, "feed.zip")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, original.WriteGTFS(file))
+	require.NoError(t, file.Close())
+
+	feed, err := LoadGTFS(path)
+	require.NoError(t, err)
+	imported := feed.Timetable()
+	assertSameQueryResults(t, &original, network.stops(), &imported, feed)
+}
+
+// assertSameQueryResults queries both timetables for all pairs of stops at different times and
+// compares the results. The stops of the second timetable are looked up by the ids of the first timetable's stops.
+func assertSameQueryResults(t *testing.T, expected *Timetable, stops []*Stop, actual *Timetable, feed *Feed) {
+	starts := []string{"7:55", "8:04", "9:30", "10:25", "12:17", "14:34", "19:50"}
+	for _, source := range stops {
+		for _, target := range stops {
+			for _, start := range starts {
+				want, wantErr := expected.Query(source, target, date(start))
+				got, gotErr := actual.Query(feed.Stop(source.Id), feed.Stop(target.Id), date(start))
+				require.Equal(t, wantErr, gotErr, "errors differ for %s -> %s at %s", source.Id, target.Id, start)
+				if want == nil {
+					continue
+				}
+				assert.Equal(t, want.Arrival, got.Arrival, "arrival differs for %s -> %s at %s", source.Id, target.Id, start)
+				require.Equal(t, len(want.Legs), len(got.Legs), "legs differ for %s -> %s at %s", source.Id, target.Id, start)
+				for i := range want.Legs {
+					assert.Equal(t, want.Legs[i].Kind, got.Legs[i].Kind, "kind of leg differs for %s -> %s at %s", source.Id, target.Id, start)
+					assert.Equal(t, want.Legs[i].FirstStop.Id, got.Legs[i].FirstStop.Id, "first stop differs for %s -> %s at %s", source.Id, target.Id, start)
+					assert.Equal(t, want.Legs[i].LastStop.Id, got.Legs[i].LastStop.Id, "last stop differs for %s -> %s at %s", source.Id, target.Id, start)
+					assert.Equal(t, want.Legs[i].Departure, got.Legs[i].Departure, "departure differs for %s -> %s at %s", source.Id, target.Id, start)
+				}
+			}
+		}
+	}
+}
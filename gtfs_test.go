@@ -0,0 +1,241 @@
+package routing
+
+import (
+	"archive/zip"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadGTFS(t *testing.T) {
+	t.Run("directory", func(t *testing.T) {
+		feed, err := LoadGTFS(filepath.Join("testdata", "gtfs"))
+		require.NoError(t, err)
+		assertTestFeed(t, feed)
+	})
+	t.Run("zip", func(t *testing.T) {
+		directory, err := ioutil.TempDir("", "gtfs")
+		require.NoError(t, err)
+		defer func() { _ = os.RemoveAll(directory) }()
+		path := filepath.Join(directory, "feed.zip")
+		zipDirectory(t, filepath.Join("testdata", "gtfs"), path)
+
+		feed, err := LoadGTFS(path)
+		require.NoError(t, err)
+		assertTestFeed(t, feed)
+	})
+	t.Run("missing file", func(t *testing.T) {
+		directory := writeFeed(t, map[string]string{"stops.txt": "stop_id,stop_name\nMS,Main Station\n", "routes.txt": "route_id\nBLUE\n"})
+		defer func() { _ = os.RemoveAll(directory) }()
+		_, err := LoadGTFS(directory)
+		assert.True(t, errors.Is(err, ErrInvalidFeed), "error should be ErrInvalidFeed")
+		assert.EqualError(t, err, "required file \"trips.txt\" is missing: invalid GTFS feed", "error message is wrong")
+	})
+	t.Run("unknown route", func(t *testing.T) {
+		directory := writeFeed(t, map[string]string{
+			"stops.txt":      "stop_id,stop_name\nMS,Main Station\n",
+			"routes.txt":     "route_id\nBLUE\n",
+			"trips.txt":      "route_id,trip_id\nRED,R1\n",
+			"stop_times.txt": "trip_id,arrival_time,departure_time,stop_id,stop_sequence\n",
+		})
+		defer func() { _ = os.RemoveAll(directory) }()
+		_, err := LoadGTFS(directory)
+		assert.True(t, errors.Is(err, ErrInvalidFeed), "error should be ErrInvalidFeed")
+		assert.EqualError(t, err, "trips.txt: trip \"R1\" references unknown route \"RED\": invalid GTFS feed", "error message is wrong")
+	})
+	t.Run("invalid time", func(t *testing.T) {
+		directory := writeFeed(t, map[string]string{
+			"stops.txt":      "stop_id,stop_name\nMS,Main Station\n",
+			"routes.txt":     "route_id\nBLUE\n",
+			"trips.txt":      "route_id,trip_id\nBLUE,B1\n",
+			"stop_times.txt": "trip_id,arrival_time,departure_time,stop_id,stop_sequence\nB1,10:5,10:05:00,MS,1\n",
+		})
+		defer func() { _ = os.RemoveAll(directory) }()
+		_, err := LoadGTFS(directory)
+		assert.True(t, errors.Is(err, ErrInvalidFeed), "error should be ErrInvalidFeed")
+		assert.EqualError(t, err, "stop_times.txt: record 1: the string \"10:5\" is invalid: time does not match the required format: invalid GTFS feed", "error message is wrong")
+	})
+	t.Run("unknown service", func(t *testing.T) {
+		directory := writeFeed(t, map[string]string{
+			"stops.txt":      "stop_id,stop_name\nMS,Main Station\n",
+			"routes.txt":     "route_id\nBLUE\n",
+			"trips.txt":      "route_id,service_id,trip_id\nBLUE,WE,B1\n",
+			"stop_times.txt": "trip_id,arrival_time,departure_time,stop_id,stop_sequence\n",
+			"calendar.txt":   "service_id,monday,tuesday,wednesday,thursday,friday,saturday,sunday,start_date,end_date\nWD,1,1,1,1,1,0,0,20200101,20201231\n",
+		})
+		defer func() { _ = os.RemoveAll(directory) }()
+		_, err := LoadGTFS(directory)
+		assert.True(t, errors.Is(err, ErrInvalidFeed), "error should be ErrInvalidFeed")
+		assert.EqualError(t, err, "trips.txt: trip \"B1\" references unknown service \"WE\": invalid GTFS feed", "error message is wrong")
+	})
+	t.Run("invalid coordinates", func(t *testing.T) {
+		directory := writeFeed(t, map[string]string{
+			"stops.txt":      "stop_id,stop_name,stop_lat,stop_lon\nMS,Main Station,49.8,9.9\nNA,North Avenue,49.8,\n",
+			"routes.txt":     "route_id\nBLUE\n",
+			"trips.txt":      "route_id,service_id,trip_id\n",
+			"stop_times.txt": "trip_id,arrival_time,departure_time,stop_id,stop_sequence\n",
+		})
+		defer func() { _ = os.RemoveAll(directory) }()
+		_, err := LoadGTFS(directory)
+		assert.True(t, errors.Is(err, ErrInvalidFeed), "error should be ErrInvalidFeed")
+		assert.EqualError(t, err, "stops.txt: stop \"NA\": the longitude \"\" is invalid: invalid GTFS feed", "error message is wrong")
+	})
+	t.Run("in-seat transfer of trips", func(t *testing.T) {
+		directory := writeFeed(t, map[string]string{
+			"stops.txt":  "stop_id,stop_name\nA,A\nB,B\nC,C\nD,D\n",
+			"routes.txt": "route_id\nL\n",
+			"trips.txt":  "route_id,trip_id\nL,T1\nL,T2\nL,T3\n",
+			"stop_times.txt": "trip_id,arrival_time,departure_time,stop_id,stop_sequence\n" +
+				"T1,08:00:00,08:00:00,A,1\nT1,08:10:00,08:10:00,B,2\n" +
+				"T2,08:10:00,08:10:00,B,1\nT2,08:20:00,08:20:00,C,2\n" +
+				"T3,08:12:00,08:12:00,B,1\nT3,08:20:00,08:20:00,D,2\n",
+			"transfers.txt": "from_stop_id,to_stop_id,from_route_id,to_route_id,from_trip_id,to_trip_id,transfer_type,min_transfer_time\n,,,,T1,T2,4,\n",
+		})
+		defer func() { _ = os.RemoveAll(directory) }()
+		feed, err := LoadGTFS(directory)
+		require.NoError(t, err)
+		timetable := feed.Timetable()
+		connection, err := timetable.Query(feed.Stop("A"), feed.Stop("C"), date("08:00"))
+		require.NoError(t, err)
+		assert.Equal(t, date("08:20"), connection.Arrival, "the in-seat transfer from T1 to T2 should be used")
+		_, err = timetable.Query(feed.Stop("A"), feed.Stop("D"), date("08:00"))
+		assert.Equal(t, ErrNoConnection, err, "the in-seat transfer must not apply to other trips of the line")
+	})
+	t.Run("transfers between routes", func(t *testing.T) {
+		directory := writeFeed(t, map[string]string{
+			"stops.txt":      "stop_id,stop_name\nMS,Main Station\nNA,North Avenue\n",
+			"routes.txt":     "route_id\nBLUE\nRED\n",
+			"trips.txt":      "route_id,trip_id\n",
+			"stop_times.txt": "trip_id,arrival_time,departure_time,stop_id,stop_sequence\n",
+			"transfers.txt": "from_stop_id,to_stop_id,from_route_id,to_route_id,from_trip_id,to_trip_id,transfer_type,min_transfer_time\n" +
+				"MS,MS,BLUE,RED,,,2,60\nMS,NA,BLUE,RED,,,2,120\n",
+		})
+		defer func() { _ = os.RemoveAll(directory) }()
+		feed, err := LoadGTFS(directory)
+		require.NoError(t, err)
+		blue, red := feed.Lines[0], feed.Lines[1]
+		assert.Equal(t, 1*time.Minute, feed.Policy.TransferTime(feed.Stop("MS"), blue, red), "transfer time at the stop is wrong")
+		assert.Equal(t, DefaultTransferTime, feed.Policy.TransferTime(feed.Stop("NA"), blue, red), "transfer time must only apply to the stop")
+		assert.Empty(t, feed.Footpaths, "footpaths are wrong")
+		assert.Contains(t, feed.Unsupported, "transfers.txt: transfer from route \"BLUE\" at stop \"MS\" to route \"RED\" at stop \"NA\" is not supported", "unsupported transfer is missing")
+	})
+	t.Run("invalid calendar date", func(t *testing.T) {
+		directory := writeFeed(t, map[string]string{
+			"stops.txt":          "stop_id,stop_name\nMS,Main Station\n",
+			"routes.txt":         "route_id\nBLUE\n",
+			"trips.txt":          "route_id,service_id,trip_id\nBLUE,WD,B1\n",
+			"stop_times.txt":     "trip_id,arrival_time,departure_time,stop_id,stop_sequence\n",
+			"calendar_dates.txt": "service_id,date,exception_type\nWD,20201016,2\nWD,2020-10-17,1\n",
+		})
+		defer func() { _ = os.RemoveAll(directory) }()
+		_, err := LoadGTFS(directory)
+		assert.True(t, errors.Is(err, ErrInvalidFeed), "error should be ErrInvalidFeed")
+		assert.EqualError(t, err, "calendar_dates.txt: record 2: the date \"2020-10-17\" is invalid: invalid GTFS feed", "error message is wrong")
+	})
+	t.Run("not existing", func(t *testing.T) {
+		_, err := LoadGTFS(filepath.Join("testdata", "not-existing"))
+		assert.True(t, os.IsNotExist(err), "error should tell that the file does not exist")
+	})
+}
+
+func assertTestFeed(t *testing.T, feed *Feed) {
+	require.Equal(t, 9, len(feed.Stops), "number of stops")
+	require.Equal(t, 2, len(feed.Lines), "number of lines")
+	require.Equal(t, 6, len(feed.Trips), "number of trips")
+	assert.Equal(t, "Docks A–E", feed.Stop("DAE").Name, "name of stop is wrong")
+	assert.Nil(t, feed.Stop("XY"), "unknown stop should be nil")
+	assert.Equal(t, &Coordinate{Lat: 49.8017, Lon: 9.9359}, feed.Stop("MS").Position, "position of stop is wrong")
+	assert.Nil(t, feed.Stop("AR").Position, "position of stop without coordinates should be nil")
+	assert.Equal(t, &Line{Id: "BLUE", Name: "Blue Line"}, feed.Lines[0], "blue line is wrong")
+	assert.Equal(t, &Line{Id: "RED", Name: "Red Line"}, feed.Lines[1], "red line is wrong")
+	assert.Equal(t, []Footpath{{From: feed.Stop("DAE"), To: feed.Stop("DFG"), Duration: 4 * time.Minute}}, feed.Footpaths, "footpaths are wrong")
+	assert.Equal(t, 6*time.Minute, feed.Policy.TransferTime(feed.Stop("NA"), feed.Lines[1], feed.Lines[0]), "stop transfer time is wrong")
+	inSeat := feed.Policy.eventTransferTime(feed.Stop("MS"), &Event{Line: feed.Lines[0], Trip: feed.Trips[2]}, &Event{Line: feed.Lines[1], Trip: feed.Trips[5]})
+	assert.Equal(t, 0*time.Minute, inSeat, "in-seat transfer is wrong")
+	assert.Equal(t, DefaultTransferTime, feed.Policy.TransferTime(feed.Stop("MS"), feed.Lines[0], feed.Lines[1]), "in-seat transfer must not apply to the lines")
+	require.Equal(t, 1, len(feed.Services), "number of services")
+	service := feed.Services[0]
+	assert.Equal(t, "WD", service.Id, "id of service is wrong")
+	assert.Equal(t, [7]bool{false, true, true, true, true, true, false}, service.Weekdays, "weekdays of service are wrong")
+	assert.Equal(t, time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC), service.Start, "start of service is wrong")
+	assert.Equal(t, time.Date(2020, time.December, 31, 0, 0, 0, 0, time.UTC), service.End, "end of service is wrong")
+	assert.Equal(t, []time.Time{time.Date(2020, time.October, 17, 0, 0, 0, 0, time.UTC)}, service.Added, "added dates are wrong")
+	assert.Equal(t, []time.Time{time.Date(2020, time.October, 16, 0, 0, 0, 0, time.UTC)}, service.Removed, "removed dates are wrong")
+	for _, trip := range feed.Trips {
+		assert.Equal(t, service, trip.Service, "service of trip %s is wrong", trip.Id)
+	}
+	assert.Equal(t, []string{
+		"file \"shapes.txt\" is not supported",
+		"stops.txt: field \"wheelchair_boarding\" is not supported",
+		"routes.txt: field \"agency_id\" is not supported",
+		"routes.txt: field \"route_type\" is not supported",
+		"transfers.txt: transfer_type \"3\" is not supported",
+	}, feed.Unsupported, "unsupported fields are wrong")
+
+	red := feed.Trips[3]
+	assert.Equal(t, "R1000", red.Id, "id of trip is wrong")
+	assert.Equal(t, []StopTime{
+		{Stop: feed.Stop("NE"), Arrival: "10:00", Departure: "10:00"},
+		{Stop: feed.Stop("NA"), Arrival: "10:02", Departure: "10:02"},
+		{Stop: feed.Stop("MS"), Arrival: "10:04", Departure: "10:04"},
+		{Stop: feed.Stop("DAE"), Arrival: "10:07", Departure: "10:07"},
+		{Stop: feed.Stop("AR"), Arrival: "10:12", Departure: "10:12"},
+	}, red.StopTimes, "stop times must be ordered by stop sequence")
+
+	timetable := feed.Timetable()
+	connection, err := timetable.Query(feed.Stop("NE"), feed.Stop("CH"), date("10:00"))
+	require.NoError(t, err)
+	require.Equal(t, 2, len(connection.Legs), "number of legs")
+	assert.Equal(t, feed.Trips[3], connection.Legs[0].Trip, "trip of first leg is wrong")
+	assert.Equal(t, feed.Trips[1], connection.Legs[1].Trip, "trip of second leg is wrong")
+	assert.Equal(t, date("10:27"), connection.Legs[1].Departure, "the transfer time of the stop is not respected")
+	assert.Equal(t, date("10:33"), connection.Arrival, "arrival is wrong")
+
+	connection, err = timetable.Query(feed.Stop("MS"), feed.Stop("DFG"), date("10:00"))
+	require.NoError(t, err)
+	assert.Equal(t, WalkingLeg, connection.Legs[len(connection.Legs)-1].Kind, "the footpath should be used")
+	assert.Equal(t, date("10:11"), connection.Arrival, "arrival is wrong")
+
+	friday := date("10:00").AddDate(0, 0, 1)
+	_, err = timetable.Query(feed.Stop("NE"), feed.Stop("CH"), friday)
+	assert.Equal(t, ErrNoConnection, err, "the service is removed on Friday and the connection on Saturday leaves after the search horizon")
+	saturday := date("10:00").AddDate(0, 0, 2)
+	connection, err = timetable.Query(feed.Stop("NE"), feed.Stop("CH"), saturday)
+	require.NoError(t, err)
+	assert.Equal(t, date("10:33").AddDate(0, 0, 2), connection.Arrival, "the service is added on Saturday")
+}
+
+func writeFeed(t *testing.T, files map[string]string) string {
+	directory, err := ioutil.TempDir("", "gtfs")
+	require.NoError(t, err)
+	for name, content := range files {
+		err := ioutil.WriteFile(filepath.Join(directory, name), []byte(content), 0644)
+		require.NoError(t, err)
+	}
+	return directory
+}
+
+func zipDirectory(t *testing.T, directory string, path string) {
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	defer func() { _ = file.Close() }()
+	writer := zip.NewWriter(file)
+	entries, err := ioutil.ReadDir(directory)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		target, err := writer.Create(entry.Name())
+		require.NoError(t, err)
+		source, err := os.Open(filepath.Join(directory, entry.Name()))
+		require.NoError(t, err)
+		_, err = io.Copy(target, source)
+		require.NoError(t, err)
+		_ = source.Close()
+	}
+	require.NoError(t, writer.Close())
+}
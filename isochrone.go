@@ -0,0 +1,107 @@
+package routing
+
+import (
+	"math"
+	"time"
+)
+
+// Locator returns the position of a stop. If the position is unknown, then false is returned.
+// StopPosition is the locator using the positions of the stops.
+type Locator func(stop *Stop) (Coordinate, bool)
+
+// FeatureCollection is a GeoJSON feature collection (RFC 7946), which can be encoded with encoding/json.
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// Feature is a GeoJSON feature with a geometry and arbitrary properties.
+type Feature struct {
+	Type       string                 `json:"type"`
+	Geometry   Geometry               `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// Geometry is a GeoJSON geometry. The coordinates of a "Point" are a single position, the coordinates of a "Polygon"
+// are a list of linear rings. Positions are given as longitude and latitude.
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// IsochroneOption customizes the computation of an isochrone.
+type IsochroneOption func(*isochrone)
+
+type isochrone struct {
+	walkingSpeed float64
+	segments     int
+}
+
+// WithWalkingBuffers adds a circle around every reached stop to the isochrone, whose radius is the distance
+// that can be walked with the given speed (in meters per second) in the time left after arriving at the stop.
+func WithWalkingBuffers(speed float64) IsochroneOption {
+	return func(i *isochrone) {
+		i.walkingSpeed = speed
+	}
+}
+
+// QueryIsochrone computes all stops that can be reached from the source within the given duration when starting
+// at the given time. The result contains a point feature for every reached stop whose position is known to the locator.
+// The properties of the feature are the "id" and the "name" of the stop, its earliest "arrival" (RFC 3339), and the
+// travel duration in "minutes". If walking buffers are enabled, then a polygon feature with the properties
+// "id" and "radius" (in meters) is added for every stop, too. If the locator is nil, then StopPosition is used.
+// The errors are the same as the errors of QueryAll.
+func (t *Timetable) QueryIsochrone(source *Stop, start time.Time, duration time.Duration, locator Locator, options ...IsochroneOption) (*FeatureCollection, error) {
+	if locator == nil {
+		locator = StopPosition
+	}
+	settings := isochrone{segments: 32}
+	for _, option := range options {
+		option(&settings)
+	}
+	arrivals, err := t.QueryAll(source, start)
+	if err != nil {
+		return nil, err
+	}
+	end := start.Add(duration)
+	result := &FeatureCollection{Type: "FeatureCollection", Features: make([]Feature, 0, 0)}
+	buffers := make([]Feature, 0, 0)
+	for _, l := range arrivals.labels {
+		stop := l.vertex.data
+		if l.weight == (time.Time{}) || l.weight.After(end) {
+			continue
+		}
+		position, ok := locator(stop)
+		if !ok {
+			continue
+		}
+		properties := map[string]interface{}{
+			"id":      stop.Id,
+			"name":    stop.Name,
+			"arrival": l.weight.Format(time.RFC3339),
+			"minutes": int(l.weight.Sub(start) / time.Minute),
+		}
+		point := Geometry{Type: "Point", Coordinates: []float64{position.Lon, position.Lat}}
+		result.Features = append(result.Features, Feature{Type: "Feature", Geometry: point, Properties: properties})
+		if settings.walkingSpeed > 0 {
+			radius := end.Sub(l.weight).Seconds() * settings.walkingSpeed
+			circle := Geometry{Type: "Polygon", Coordinates: [][][]float64{position.circle(radius, settings.segments)}}
+			buffers = append(buffers, Feature{Type: "Feature", Geometry: circle, Properties: map[string]interface{}{"id": stop.Id, "radius": radius}})
+		}
+	}
+	result.Features = append(result.Features, buffers...)
+	return result, nil
+}
+
+// circle approximates the circle with the given radius (in meters) around the coordinate by a closed ring
+// of positions in counterclockwise order.
+func (c Coordinate) circle(radius float64, segments int) [][]float64 {
+	latitude := radius / earthRadius * 180 / math.Pi
+	longitude := latitude / math.Cos(c.Lat*math.Pi/180)
+	result := make([][]float64, 0, segments+1)
+	for i := 0; i < segments; i++ {
+		angle := 2 * math.Pi * float64(i) / float64(segments)
+		result = append(result, []float64{c.Lon + longitude*math.Cos(angle), c.Lat + latitude*math.Sin(angle)})
+	}
+	return append(result, result[0])
+}
@@ -0,0 +1,86 @@
+package routing
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTimetable_QueryIsochrone(t *testing.T) {
+	network := createTestNetwork()
+	timetable := NewTimetable(network.stops())
+	positions := map[*Stop]Coordinate{
+		network.historicMall:   {Lat: 49.7913, Lon: 9.9534},
+		network.schusterStreet: {Lat: 49.7925, Lon: 9.9561},
+		network.chalet:         {Lat: 49.7950, Lon: 9.9602},
+		network.mainStation:    {Lat: 49.8017, Lon: 9.9359},
+	}
+	locator := func(stop *Stop) (Coordinate, bool) {
+		position, ok := positions[stop]
+		return position, ok
+	}
+
+	t.Run("stops", func(t *testing.T) {
+		isochrone, err := timetable.QueryIsochrone(network.historicMall, date("8:00"), 30*time.Minute, locator)
+		require.NoError(t, err)
+		assert.Equal(t, "FeatureCollection", isochrone.Type, "type is wrong")
+		// north avenue and main station are not reached within 30 minutes, north avenue has no position
+		require.Equal(t, 3, len(isochrone.Features), "number of features")
+		ids := make(map[string]Feature)
+		for _, feature := range isochrone.Features {
+			ids[feature.Properties["id"].(string)] = feature
+		}
+		chalet := ids["CH"]
+		assert.Equal(t, "Point", chalet.Geometry.Type, "geometry is wrong")
+		assert.Equal(t, []float64{9.9602, 49.7950}, chalet.Geometry.Coordinates, "coordinates are wrong")
+		assert.Equal(t, "Chalet", chalet.Properties["name"], "name is wrong")
+		assert.Equal(t, "2020-10-15T08:13:00Z", chalet.Properties["arrival"], "arrival is wrong")
+		assert.Equal(t, 13, chalet.Properties["minutes"], "minutes are wrong")
+		assert.Equal(t, 0, ids["HM"].Properties["minutes"], "source should be contained")
+	})
+	t.Run("walking buffers", func(t *testing.T) {
+		isochrone, err := timetable.QueryIsochrone(network.historicMall, date("8:00"), 30*time.Minute, locator, WithWalkingBuffers(1.2))
+		require.NoError(t, err)
+		require.Equal(t, 6, len(isochrone.Features), "number of features")
+		buffer := isochrone.Features[5]
+		assert.Equal(t, "Polygon", buffer.Geometry.Type, "geometry is wrong")
+		assert.Equal(t, "CH", buffer.Properties["id"], "id is wrong")
+		assert.InDelta(t, 17*60*1.2, buffer.Properties["radius"], 1e-9, "radius is wrong")
+		rings := buffer.Geometry.Coordinates.([][][]float64)
+		require.Equal(t, 1, len(rings), "number of rings")
+		ring := rings[0]
+		assert.Equal(t, ring[0], ring[len(ring)-1], "ring should be closed")
+		for _, position := range ring {
+			distance := math.Hypot((position[0]-9.9602)*math.Cos(49.7950*math.Pi/180), position[1]-49.7950) * math.Pi / 180 * earthRadius
+			assert.InDelta(t, 17*60*1.2, distance, 1, "point of the buffer is not on the circle")
+		}
+	})
+	t.Run("json", func(t *testing.T) {
+		isochrone, err := timetable.QueryIsochrone(network.schusterStreet, date("8:00"), 15*time.Minute, locator)
+		require.NoError(t, err)
+		data, err := json.Marshal(isochrone)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"type": "FeatureCollection", "features": [
+			{"type": "Feature", "geometry": {"type": "Point", "coordinates": [9.9561, 49.7925]}, "properties": {"id": "SS", "name": "Schuster Street", "arrival": "2020-10-15T08:00:00Z", "minutes": 0}},
+			{"type": "Feature", "geometry": {"type": "Point", "coordinates": [9.9602, 49.795]}, "properties": {"id": "CH", "name": "Chalet", "arrival": "2020-10-15T08:13:00Z", "minutes": 13}}
+		]}`, string(data), "json is wrong")
+	})
+	t.Run("stop positions", func(t *testing.T) {
+		network := createTestNetwork()
+		network.chalet.Position = &Coordinate{Lat: 49.7950, Lon: 9.9602}
+		timetable := NewTimetable(network.stops())
+		isochrone, err := timetable.QueryIsochrone(network.historicMall, date("8:00"), 30*time.Minute, nil)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(isochrone.Features), "only stops with positions should be contained")
+		assert.Equal(t, "CH", isochrone.Features[0].Properties["id"], "id is wrong")
+	})
+	t.Run("unknown stop", func(t *testing.T) {
+		isochrone, err := timetable.QueryIsochrone(NewStop("XY", "Unknown"), date("8:00"), 30*time.Minute, locator)
+		assert.True(t, errors.Is(err, ErrUnknownStop), "error should be ErrUnknownStop")
+		assert.Nil(t, isochrone, "no isochrone should be returned")
+	})
+}
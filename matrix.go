@@ -0,0 +1,88 @@
+package routing
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Unreachable is the travel duration in a TravelTimeMatrix if a destination cannot be reached.
+const Unreachable time.Duration = -1
+
+// TravelTimeMatrix contains the travel durations between origins and destinations for several departure times.
+// Durations is indexed by the departure, the origin, and the destination in the order of the query,
+// e.g. Durations[0][1][2] is the duration from the second origin to the third destination at the first departure.
+type TravelTimeMatrix struct {
+	Origins      []*Stop
+	Destinations []*Stop
+	Departures   []time.Time
+	Durations    [][][]time.Duration
+}
+
+// Duration returns the travel duration between the origin and the destination at the departure, all given by their
+// indices. If the destination cannot be reached, then false is returned.
+func (m *TravelTimeMatrix) Duration(departure int, origin int, destination int) (time.Duration, bool) {
+	duration := m.Durations[departure][origin][destination]
+	return duration, duration != Unreachable
+}
+
+// QueryMatrix computes the travel durations from all origins to all destinations for every departure time,
+// i.e. the durations between the departure at the origin and the earliest arrival at the destination.
+// Only one search is run per origin and departure, and these searches run in parallel.
+// If an origin or destination is not part of the timetable, then ErrUnknownStop is returned.
+// If a departure time of the timetable is malformed, then ErrInvalidTime is returned.
+func (t *Timetable) QueryMatrix(origins []*Stop, destinations []*Stop, departures []time.Time) (*TravelTimeMatrix, error) {
+	sources := make([]*vertex, 0, len(origins))
+	for _, origin := range origins {
+		s, err := t.vertex(origin, "origin")
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, s)
+	}
+	targets := make([]*vertex, 0, len(destinations))
+	for _, destination := range destinations {
+		ta, err := t.vertex(destination, "destination")
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, ta)
+	}
+	if t.invalid != nil {
+		return nil, t.invalid
+	}
+	result := &TravelTimeMatrix{Origins: origins, Destinations: destinations, Departures: departures, Durations: make([][][]time.Duration, len(departures))}
+	type job struct {
+		departure int
+		origin    int
+	}
+	jobs := make(chan job)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				start := departures[job.departure]
+				labels := t.graph.earliestArrivals(sources[job.origin], nil, start, start.Add(t.horizon))
+				durations := result.Durations[job.departure][job.origin]
+				for i, target := range targets {
+					durations[i] = Unreachable
+					if arrival := labels[target.id].weight; arrival != (time.Time{}) {
+						durations[i] = arrival.Sub(start)
+					}
+				}
+			}
+		}()
+	}
+	for departure := range departures {
+		result.Durations[departure] = make([][]time.Duration, len(origins))
+		for origin := range origins {
+			result.Durations[departure][origin] = make([]time.Duration, len(destinations))
+			jobs <- job{departure: departure, origin: origin}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return result, nil
+}
@@ -0,0 +1,55 @@
+package routing
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestTimetable_QueryMatrix(t *testing.T) {
+	network := createTestNetwork()
+	timetable := NewTimetable(network.stops())
+	origins := []*Stop{network.northEnd, network.mainStation, network.marketPlace}
+	destinations := []*Stop{network.chalet, network.airport, network.northEnd}
+	departures := []time.Time{date("8:04"), date("10:25")}
+
+	t.Run("durations", func(t *testing.T) {
+		matrix, err := timetable.QueryMatrix(origins, destinations, departures)
+		require.NoError(t, err)
+		require.Equal(t, len(departures), len(matrix.Durations), "number of departures")
+		for d, departure := range departures {
+			require.Equal(t, len(origins), len(matrix.Durations[d]), "number of origins")
+			for o, origin := range origins {
+				require.Equal(t, len(destinations), len(matrix.Durations[d][o]), "number of destinations")
+				for i, destination := range destinations {
+					duration, ok := matrix.Duration(d, o, i)
+					connection, err := timetable.Query(origin, destination, departure)
+					if origin == destination {
+						assert.True(t, ok, "%s -> %s at %v: origin should be reached", origin.Id, destination.Id, departure)
+						assert.Equal(t, time.Duration(0), duration, "%s -> %s at %v: duration is wrong", origin.Id, destination.Id, departure)
+					} else if err != nil {
+						assert.False(t, ok, "%s -> %s at %v: destination should not be reached", origin.Id, destination.Id, departure)
+						assert.Equal(t, Unreachable, duration, "%s -> %s at %v: duration is wrong", origin.Id, destination.Id, departure)
+					} else {
+						assert.True(t, ok, "%s -> %s at %v: destination should be reached", origin.Id, destination.Id, departure)
+						assert.Equal(t, connection.Arrival.Sub(departure), duration, "%s -> %s at %v: duration is wrong", origin.Id, destination.Id, departure)
+					}
+				}
+			}
+		}
+		duration, _ := matrix.Duration(1, 0, 0)
+		assert.Equal(t, 28*time.Minute, duration, "duration from north end to chalet at 10:25 is wrong")
+	})
+	t.Run("unknown stop", func(t *testing.T) {
+		matrix, err := timetable.QueryMatrix(origins, []*Stop{NewStop("XY", "Unknown")}, departures)
+		assert.True(t, errors.Is(err, ErrUnknownStop), "error should be ErrUnknownStop")
+		assert.Nil(t, matrix, "no matrix should be returned")
+	})
+	t.Run("empty", func(t *testing.T) {
+		matrix, err := timetable.QueryMatrix(nil, destinations, departures)
+		require.NoError(t, err)
+		assert.Equal(t, [][][]time.Duration{{}, {}}, matrix.Durations, "durations are wrong")
+	})
+}
@@ -0,0 +1,113 @@
+package routing
+
+import (
+	"fmt"
+	"time"
+)
+
+// QueryOptions constrain the connections computed by QueryWithOptions.
+type QueryOptions struct {
+	// Via contains stops which must be visited in the given order.
+	Via []Via
+	// AvoidStops contains stops which must not be used, e.g. because they are closed.
+	// Vehicles cannot be used to pass an avoided stop either.
+	AvoidStops []*Stop
+	// AvoidLines contains lines which must not be used.
+	AvoidLines []*Line
+}
+
+// Via is a stop which must be visited by a connection. If MinDwell is zero, then passing the stop
+// in a vehicle suffices. Otherwise, the passenger leaves the vehicle at the stop and stays there for at least
+// MinDwell. Afterwards, the passenger continues like from the source of the connection, i.e. without transfer time.
+type Via struct {
+	Stop     *Stop
+	MinDwell time.Duration
+}
+
+// QueryWithOptions computes the fastest route between source and target with the specified start time
+// like Query, but only connections satisfying the options are considered. The constraints are enforced during
+// the search: the connection is computed as a sequence of searches from one via stop to the next, and the searches
+// neither reach avoided stops nor use departures of avoided lines.
+//
+// If a via stop is not part of the timetable, then ErrUnknownStop is returned. Avoided stops which are
+// not part of the timetable are ignored. The other errors are the same as the errors of Query.
+func (t *Timetable) QueryWithOptions(source *Stop, target *Stop, start time.Time, options QueryOptions) (*Connection, error) {
+	s, err := t.vertex(source, "source")
+	if err != nil {
+		return nil, err
+	}
+	ta, err := t.vertex(target, "target")
+	if err != nil {
+		return nil, err
+	}
+	stops := make([]*vertex, 0, len(options.Via)+1)
+	for i, via := range options.Via {
+		v, err := t.vertex(via.Stop, fmt.Sprintf("via stop %d", i+1))
+		if err != nil {
+			return nil, err
+		}
+		stops = append(stops, v)
+	}
+	stops = append(stops, ta)
+	if t.invalid != nil {
+		return nil, t.invalid
+	}
+	constraints := t.constraints(options)
+	deadline := start.Add(t.horizon)
+	path := []*label{{vertex: s, weight: start}}
+	// the passenger leaves the vehicle at via stops with a dwell, thus the legs of the parts are never merged
+	parts := make([][]*label, 0, len(stops))
+	for i, next := range stops {
+		current := path[len(path)-1]
+		if constraints.avoided[current.vertex.id] || constraints.avoided[next.id] {
+			return nil, ErrNoConnection
+		}
+		labels := t.graph.labels()
+		labels[current.vertex.id].weight = current.weight
+		labels[current.vertex.id].event = current.event
+		if i > 0 && options.Via[i-1].MinDwell > 0 {
+			labels[current.vertex.id].weight = current.weight.Add(options.Via[i-1].MinDwell)
+			labels[current.vertex.id].event = nil
+			parts = append(parts, path)
+			path = []*label{current}
+		}
+		t.graph.search(labels, []*label{&labels[current.vertex.id]}, deadline, constraints, func(l *label) bool {
+			return l.vertex == next
+		})
+		if labels[next.id].weight == (time.Time{}) {
+			return nil, ErrNoConnection
+		}
+		// the first label of the segment is the last label of the path
+		path = append(path, pathTo(&labels[next.id])[1:]...)
+	}
+	parts = append(parts, path)
+	result := &Connection{Arrival: path[len(path)-1].weight}
+	for _, part := range parts {
+		if connection := createConnection(part); connection != nil {
+			result.Legs = append(result.Legs, connection.Legs...)
+		}
+	}
+	if len(result.Legs) == 0 {
+		return nil, ErrNoConnection
+	}
+	return result, nil
+}
+
+func (t *Timetable) constraints(options QueryOptions) *constraints {
+	result := &constraints{avoided: make([]bool, len(t.graph.vertices))}
+	for _, stop := range options.AvoidStops {
+		if t.contains(stop) {
+			result.avoided[t.stops[stop.Id].id] = true
+		}
+	}
+	if len(options.AvoidLines) != 0 {
+		lines := make(map[*Line]bool)
+		for _, line := range options.AvoidLines {
+			lines[line] = true
+		}
+		result.allowed = func(event *Event) bool {
+			return event.Line == nil || !lines[event.Line]
+		}
+	}
+	return result
+}
@@ -0,0 +1,133 @@
+package routing
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestTimetable_QueryWithOptions(t *testing.T) {
+	network := createTestNetwork()
+	timetable := NewTimetable(network.stops())
+
+	t.Run("no options", func(t *testing.T) {
+		expected, err := timetable.Query(network.northEnd, network.chalet, date("10:25"))
+		require.NoError(t, err)
+		connection, err := timetable.QueryWithOptions(network.northEnd, network.chalet, date("10:25"), QueryOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, expected, connection, "connection should be the same as without options")
+	})
+	t.Run("via", func(t *testing.T) {
+		options := QueryOptions{Via: []Via{{Stop: network.mainStation}}}
+		connection, err := timetable.QueryWithOptions(network.northEnd, network.chalet, date("10:25"), options)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(connection.Legs), "number of legs")
+		assert.Equal(t, network.mainStation, connection.Legs[0].LastStop, "the red line should be left at main station")
+		assert.Equal(t, date("10:29"), connection.Legs[0].Arrival, "arrival at main station is wrong")
+		assert.Equal(t, date("10:45"), connection.Legs[1].Departure, "departure at main station is wrong")
+		assert.Equal(t, date("10:53"), connection.Arrival, "arrival is wrong")
+	})
+	t.Run("via in vehicle", func(t *testing.T) {
+		options := QueryOptions{Via: []Via{{Stop: network.northAvenue}}}
+		connection, err := timetable.QueryWithOptions(network.northEnd, network.docksAE, date("10:25"), options)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(connection.Legs), "passing the via stop in the vehicle should suffice")
+		assert.Equal(t, date("10:32"), connection.Arrival, "arrival is wrong")
+	})
+	t.Run("via with dwell", func(t *testing.T) {
+		options := QueryOptions{Via: []Via{{Stop: network.mainStation, MinDwell: 20 * time.Minute}}}
+		connection, err := timetable.QueryWithOptions(network.northEnd, network.chalet, date("10:25"), options)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(connection.Legs), "number of legs")
+		assert.Equal(t, date("11:05"), connection.Legs[1].Departure, "departure after the dwell is wrong")
+		assert.Equal(t, 36*time.Minute, connection.Legs[1].Wait, "wait at main station is wrong")
+		assert.Equal(t, date("11:13"), connection.Arrival, "arrival is wrong")
+
+		options = QueryOptions{Via: []Via{{Stop: network.northAvenue, MinDwell: 2 * time.Minute}}}
+		connection, err = timetable.QueryWithOptions(network.northEnd, network.docksAE, date("10:25"), options)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(connection.Legs), "the leg should be broken at the via stop")
+		assert.Equal(t, network.northAvenue, connection.Legs[0].LastStop, "first leg should end at north avenue")
+		assert.Equal(t, date("10:27"), connection.Legs[0].Arrival, "arrival at north avenue is wrong")
+		assert.Equal(t, network.northAvenue, connection.Legs[1].FirstStop, "second leg should start at north avenue")
+		assert.Equal(t, date("10:32"), connection.Legs[1].Departure, "the next vehicle should be used after the dwell")
+		assert.Equal(t, 5*time.Minute, connection.Legs[1].Wait, "wait at north avenue is wrong")
+		assert.Equal(t, date("10:37"), connection.Arrival, "arrival is wrong")
+	})
+	t.Run("several via stops", func(t *testing.T) {
+		options := QueryOptions{Via: []Via{{Stop: network.mainStation, MinDwell: time.Minute}, {Stop: network.northAvenue}, {Stop: network.northAvenue}}}
+		connection, err := timetable.QueryWithOptions(network.northEnd, network.chalet, date("10:25"), options)
+		require.NoError(t, err)
+		assert.Equal(t, date("10:53"), connection.Arrival, "arrival is wrong")
+		assert.Equal(t, network.mainStation, connection.Legs[0].LastStop, "main station should be visited")
+	})
+	t.Run("avoid stops", func(t *testing.T) {
+		options := QueryOptions{AvoidStops: []*Stop{network.northAvenue, NewStop("XY", "Unknown")}}
+		connection, err := timetable.QueryWithOptions(network.northEnd, network.chalet, date("10:25"), options)
+		assert.Equal(t, ErrNoConnection, err, "north avenue cannot be passed")
+		assert.Nil(t, connection, "no connection should be returned")
+
+		connection, err = timetable.QueryWithOptions(network.northEnd, network.northAvenue, date("10:25"), options)
+		assert.Equal(t, ErrNoConnection, err, "avoided target cannot be reached")
+		assert.Nil(t, connection, "no connection should be returned")
+
+		options.Via = []Via{{Stop: network.northAvenue}}
+		connection, err = timetable.QueryWithOptions(network.northEnd, network.chalet, date("10:25"), options)
+		assert.Equal(t, ErrNoConnection, err, "avoided via stop cannot be reached")
+		assert.Nil(t, connection, "no connection should be returned")
+	})
+	t.Run("avoid lines", func(t *testing.T) {
+		options := QueryOptions{AvoidLines: []*Line{network.redLine}}
+		connection, err := timetable.QueryWithOptions(network.northEnd, network.chalet, date("10:25"), options)
+		assert.Equal(t, ErrNoConnection, err, "north end is only served by the red line")
+		assert.Nil(t, connection, "no connection should be returned")
+	})
+	t.Run("unknown via stop", func(t *testing.T) {
+		options := QueryOptions{Via: []Via{{Stop: NewStop("XY", "Unknown")}}}
+		connection, err := timetable.QueryWithOptions(network.northEnd, network.chalet, date("10:25"), options)
+		assert.True(t, errors.Is(err, ErrUnknownStop), "error should be ErrUnknownStop")
+		assert.EqualError(t, err, "via stop 1 \"XY\": stop not found in the timetable", "error message is wrong")
+		assert.Nil(t, connection, "no connection should be returned")
+	})
+}
+
+func TestTimetable_QueryWithOptionsAlternatives(t *testing.T) {
+	zoo := NewStop("ZO", "Zoo")
+	hall := NewStop("HA", "Hall")
+	court := NewStop("CO", "Court")
+	mall := NewStop("MA", "Mall")
+	express := &Line{Id: "1", Name: "Express"}
+	local := &Line{Id: "2", Name: "Local"}
+	slow := &Line{Id: "3", Name: "Slow"}
+	_, err := NewTrip("1-0800", express, []StopTime{{Stop: zoo, Departure: "8:00"}, {Stop: hall, Arrival: "8:02", Departure: "8:02"}, {Stop: mall, Arrival: "8:05"}})
+	require.NoError(t, err)
+	_, err = NewTrip("3-0801", slow, []StopTime{{Stop: zoo, Departure: "8:01"}, {Stop: court, Arrival: "8:04", Departure: "8:04"}, {Stop: mall, Arrival: "8:08"}})
+	require.NoError(t, err)
+	_, err = NewTrip("2-0802", local, []StopTime{{Stop: zoo, Departure: "8:02"}, {Stop: mall, Arrival: "8:20"}})
+	require.NoError(t, err)
+	timetable := NewTimetable([]*Stop{zoo, hall, court, mall})
+
+	tests := []struct {
+		name    string
+		options QueryOptions
+		line    *Line
+		arrival string
+	}{
+		{name: "fastest", line: express, arrival: "8:05"},
+		{name: "avoid hall", options: QueryOptions{AvoidStops: []*Stop{hall}}, line: slow, arrival: "8:08"},
+		{name: "avoid express", options: QueryOptions{AvoidLines: []*Line{express}}, line: slow, arrival: "8:08"},
+		{name: "avoid express and court", options: QueryOptions{AvoidStops: []*Stop{court}, AvoidLines: []*Line{express}}, line: local, arrival: "8:20"},
+		{name: "avoid express and slow", options: QueryOptions{AvoidLines: []*Line{express, slow}}, line: local, arrival: "8:20"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connection, err := timetable.QueryWithOptions(zoo, mall, date("7:55"), tt.options)
+			require.NoError(t, err)
+			require.Equal(t, 1, len(connection.Legs), "number of legs")
+			assert.Equal(t, tt.line, connection.Legs[0].Line, "line is wrong")
+			assert.Equal(t, date(tt.arrival), connection.Arrival, "arrival is wrong")
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package routing
+
+import (
+	"sort"
+	"time"
+)
+
+// QueryPareto computes all connections between source and target which are optimal regarding
+// the arrival time and the number of transfers: for every connection of the result, there is no other
+// connection which arrives not later and needs fewer transfers. Connections with more than maxTransfers
+// transfers are not considered; if maxTransfers is negative, only walking is allowed.
+//
+// The connections are computed with the rounds of the RAPTOR router of the timetable, see Raptor: round k
+// contains the fastest arrivals using at most k vehicles, thus every round which improves the arrival at the target
+// contributes a connection. The fastest connection may arrive earlier than the connection of Query: if a stop is
+// reached at the same time by two vehicles, the Dijkstra search of Query keeps only one of them.
+//
+// The result is sorted by the number of transfers, thus the last connection is the fastest one.
+// The errors are the same as the errors of Query.
+func (t *Timetable) QueryPareto(source *Stop, target *Stop, start time.Time, maxTransfers int) ([]*Connection, error) {
+	s, err := t.vertex(source, "source")
+	if err != nil {
+		return nil, err
+	}
+	ta, err := t.vertex(target, "target")
+	if err != nil {
+		return nil, err
+	}
+	if t.invalid != nil {
+		return nil, t.invalid
+	}
+	raptor := t.raptor()
+	if raptor.invalid != nil {
+		return nil, raptor.invalid
+	}
+	rides := maxTransfers + 1
+	if rides < 0 {
+		rides = 0
+	}
+	rounds := raptor.search(s.id, ta.id, start, start.Add(t.horizon), rides, nil)
+	candidates := make([]*Connection, 0, 0)
+	for round, labels := range rounds {
+		if !labels[ta.id].reached || (round > 0 && rounds[round-1][ta.id].reached && !labels[ta.id].arrival.Before(rounds[round-1][ta.id].arrival)) {
+			continue
+		}
+		if connection := createConnection(raptor.path(rounds, round, ta.id)); connection != nil {
+			candidates = append(candidates, connection)
+		}
+	}
+	// a path of a later round may need fewer transfers than the number of its round
+	result := make([]*Connection, 0, len(candidates))
+	for i, connection := range candidates {
+		dominated := false
+		for j, other := range candidates {
+			better := other.Arrival.Before(connection.Arrival) || other.Transfers() < connection.Transfers()
+			if i != j && !other.Arrival.After(connection.Arrival) && other.Transfers() <= connection.Transfers() && (better || j < i) {
+				dominated = true
+				break
+			}
+		}
+		if !dominated {
+			result = append(result, connection)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Transfers() < result[j].Transfers()
+	})
+	if len(result) == 0 {
+		return nil, ErrNoConnection
+	}
+	return result, nil
+}
+
+// Transfers returns the number of changes between vehicles in the connection. Walking is not a transfer.
+func (c *Connection) Transfers() int {
+	rides := 0
+	for _, leg := range c.Legs {
+		if leg.Kind == TransitLeg {
+			rides++
+		}
+	}
+	if rides == 0 {
+		return 0
+	}
+	return rides - 1
+}
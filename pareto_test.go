@@ -0,0 +1,120 @@
+package routing
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestTimetable_QueryPareto(t *testing.T) {
+	zoo := NewStop("ZO", "Zoo")
+	mall := NewStop("MA", "Mall")
+	court := NewStop("CO", "Court")
+	harbour := NewStop("HA", "Harbour")
+	slow := &Line{Name: "1 Slow", Id: "1"}
+	fast := &Line{Name: "2 Fast", Id: "2"}
+	express := &Line{Name: "3 Express", Id: "3"}
+	shuttle := &Line{Name: "4 Shuttle", Id: "4"}
+	direct, err := NewTrip("1-0800", slow, []StopTime{{Stop: zoo, Departure: "8:00"}, {Stop: mall, Arrival: "8:20", Departure: "8:20"}, {Stop: harbour, Arrival: "8:50"}})
+	require.NoError(t, err)
+	first, err := NewTrip("2-0805", fast, []StopTime{{Stop: zoo, Departure: "8:05"}, {Stop: mall, Arrival: "8:10"}})
+	require.NoError(t, err)
+	second, err := NewTrip("3-0815", express, []StopTime{{Stop: mall, Departure: "8:15"}, {Stop: court, Arrival: "8:20"}})
+	require.NoError(t, err)
+	third, err := NewTrip("4-0825", shuttle, []StopTime{{Stop: court, Departure: "8:25"}, {Stop: harbour, Arrival: "8:30"}})
+	require.NoError(t, err)
+	_, err = NewTrip("3-0830", express, []StopTime{{Stop: mall, Departure: "8:30"}, {Stop: harbour, Arrival: "8:40"}})
+	require.NoError(t, err)
+	timetable := NewTimetable([]*Stop{zoo, mall, court, harbour})
+
+	t.Run("all transfers", func(t *testing.T) {
+		connections, err := timetable.QueryPareto(zoo, harbour, date("7:55"), 5)
+		require.NoError(t, err)
+		require.Equal(t, 3, len(connections), "number of connections")
+		assert.Equal(t, 0, connections[0].Transfers(), "transfers of first connection")
+		assert.Equal(t, direct, connections[0].Legs[0].Trip, "first connection should be direct")
+		assert.Equal(t, date("8:50"), connections[0].Arrival, "arrival of first connection")
+		assert.Equal(t, 1, connections[1].Transfers(), "transfers of second connection")
+		assert.Equal(t, date("8:40"), connections[1].Arrival, "arrival of second connection")
+		assert.Equal(t, 2, connections[2].Transfers(), "transfers of third connection")
+		assert.Equal(t, []*Trip{first, second, third}, []*Trip{connections[2].Legs[0].Trip, connections[2].Legs[1].Trip, connections[2].Legs[2].Trip}, "trips of third connection")
+		assert.Equal(t, date("8:30"), connections[2].Arrival, "arrival of third connection")
+
+		fastest, err := timetable.Query(zoo, harbour, date("7:55"))
+		require.NoError(t, err)
+		assert.Equal(t, fastest.Arrival, connections[2].Arrival, "the last connection must be the fastest")
+	})
+	t.Run("max transfers", func(t *testing.T) {
+		connections, err := timetable.QueryPareto(zoo, harbour, date("7:55"), 1)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(connections), "number of connections")
+		assert.Equal(t, date("8:50"), connections[0].Arrival, "arrival of first connection")
+		assert.Equal(t, date("8:40"), connections[1].Arrival, "arrival of second connection")
+
+		connections, err = timetable.QueryPareto(zoo, harbour, date("7:55"), 0)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(connections), "number of connections")
+		assert.Equal(t, direct, connections[0].Legs[0].Trip, "only the direct connection is allowed")
+	})
+	t.Run("no connection", func(t *testing.T) {
+		connections, err := timetable.QueryPareto(zoo, court, date("8:10"), 0)
+		assert.Equal(t, ErrNoConnection, err, "court cannot be reached without transfer")
+		assert.Nil(t, connections, "court cannot be reached without transfer")
+	})
+	t.Run("unknown stop", func(t *testing.T) {
+		connections, err := timetable.QueryPareto(zoo, NewStop("XY", "Unknown"), date("8:10"), 2)
+		assert.True(t, errors.Is(err, ErrUnknownStop), "error should be ErrUnknownStop")
+		assert.Nil(t, connections, "no connections should be returned")
+	})
+	t.Run("grid network", func(t *testing.T) {
+		grid := createGridNetwork(5, 10)
+		timetable := NewTimetable(flattenGrid(grid))
+		connections, err := timetable.QueryPareto(grid[0][0], grid[4][4], date("8:00"), 3)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(connections), "number of connections")
+		assert.Equal(t, 1, connections[0].Transfers(), "a single transfer is the fastest connection, too")
+		raptor := NewRaptor(&timetable)
+		for _, source := range flattenGrid(grid) {
+			for _, target := range flattenGrid(grid) {
+				fastest, err := raptor.Query(source, target, date("8:00"))
+				connections, paretoErr := timetable.QueryPareto(source, target, date("8:00"), 10)
+				require.Equal(t, err, paretoErr, "errors differ for %s -> %s", source.Id, target.Id)
+				if err == nil {
+					assert.Equal(t, fastest.Arrival, connections[len(connections)-1].Arrival, "%s -> %s: the last connection must be the fastest", source.Id, target.Id)
+				}
+			}
+		}
+	})
+	t.Run("test network", func(t *testing.T) {
+		network := createTestNetwork()
+		footpath := Footpath{From: network.docksAE, To: network.docksFG, Duration: 4 * time.Minute}
+		timetable := NewTimetable(network.stops(), WithFootpaths(footpath))
+		for _, source := range network.stops() {
+			for _, target := range network.stops() {
+				for _, start := range []string{"8:04", "10:25", "14:34"} {
+					fastest, err := timetable.Query(source, target, date(start))
+					connections, paretoErr := timetable.QueryPareto(source, target, date(start), 10)
+					require.Equal(t, err, paretoErr, "errors differ for %s -> %s at %s", source.Id, target.Id, start)
+					if err != nil {
+						continue
+					}
+					last := connections[len(connections)-1]
+					assert.Equal(t, fastest.Arrival, last.Arrival, "%s -> %s at %s: the last connection must be the fastest", source.Id, target.Id, start)
+					for i := 1; i < len(connections); i++ {
+						assert.True(t, connections[i].Arrival.Before(connections[i-1].Arrival), "%s -> %s at %s: arrivals must decrease", source.Id, target.Id, start)
+						assert.True(t, connections[i].Transfers() > connections[i-1].Transfers(), "%s -> %s at %s: transfers must increase", source.Id, target.Id, start)
+					}
+				}
+			}
+		}
+	})
+}
+
+func TestConnection_Transfers(t *testing.T) {
+	assert.Equal(t, 0, (&Connection{}).Transfers(), "empty connection")
+	assert.Equal(t, 0, (&Connection{Legs: []Leg{{Kind: WalkingLeg}}}).Transfers(), "walking only")
+	assert.Equal(t, 0, (&Connection{Legs: []Leg{{Kind: WalkingLeg}, {Kind: TransitLeg}, {Kind: WalkingLeg}}}).Transfers(), "single ride")
+	assert.Equal(t, 2, (&Connection{Legs: []Leg{{Kind: TransitLeg}, {Kind: WalkingLeg}, {Kind: TransitLeg}, {Kind: TransitLeg}}}).Transfers(), "three rides")
+}
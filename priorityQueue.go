@@ -5,7 +5,7 @@ import (
 	"time"
 )
 
-type priorityQueue []*vertex
+type priorityQueue []*label
 
 func (p priorityQueue) Len() int {
 	return len(p)
@@ -30,7 +30,7 @@ func (p priorityQueue) Swap(i int, j int) {
 
 func (p *priorityQueue) Push(x interface{}) {
 	n := len(*p)
-	item := x.(*vertex)
+	item := x.(*label)
 	item.index = n
 	*p = append(*p, item)
 }
@@ -45,6 +45,6 @@ func (p *priorityQueue) Pop() interface{} {
 	return item
 }
 
-func (p *priorityQueue) update(vertex *vertex) {
-	heap.Fix(p, vertex.index)
+func (p *priorityQueue) update(label *label) {
+	heap.Fix(p, label.index)
 }
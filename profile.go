@@ -0,0 +1,132 @@
+package routing
+
+import (
+	"sort"
+	"time"
+)
+
+// QueryProfile computes all optimal connections between source and target which depart
+// between from and to (both inclusive). A connection is optimal if no other connection departs
+// later and arrives earlier (or at the same time). The result is sorted by departure.
+// The profile is computed by the RAPTOR router of the timetable, see Raptor.QueryProfile.
+//
+// The errors are the same as the errors of Query, ErrNoConnection is returned if there is no
+// connection departing within the window.
+func (t *Timetable) QueryProfile(source *Stop, target *Stop, from time.Time, to time.Time) ([]*Connection, error) {
+	if _, err := t.vertex(source, "source"); err != nil {
+		return nil, err
+	}
+	if _, err := t.vertex(target, "target"); err != nil {
+		return nil, err
+	}
+	if t.invalid != nil {
+		return nil, t.invalid
+	}
+	return t.raptor().QueryProfile(source, target, from, to)
+}
+
+// QueryProfile computes all optimal connections between source and target which depart between from and to
+// (both inclusive), see QueryProfile of the timetable. It implements rRAPTOR: the departures at the source, and at
+// the stops reachable on foot from the source, are searched from the latest to the earliest one. The earliest arrivals
+// of later departures are kept, because a passenger departing earlier can always wait for them. Thus, every
+// search only explores the stops it reaches earlier than the searches before, and it reaches the target only if it
+// finds a connection which arrives earlier than all connections departing later.
+//
+// Connections consisting only of walking can be used at any time, thus such a connection is contained only once,
+// departing at from.
+func (r *Raptor) QueryProfile(source *Stop, target *Stop, from time.Time, to time.Time) ([]*Connection, error) {
+	s, err := r.timetable.vertex(source, "source")
+	if err != nil {
+		return nil, err
+	}
+	ta, err := r.timetable.vertex(target, "target")
+	if err != nil {
+		return nil, err
+	}
+	if r.invalid != nil {
+		return nil, r.invalid
+	}
+	result := make([]*Connection, 0, 0)
+	walking := r.search(s.id, ta.id, from, from.Add(r.timetable.horizon), 0, nil)[0]
+	if walking[ta.id].reached {
+		if connection := createConnection(r.path([][]raptorLabel{walking}, 0, ta.id)); connection != nil {
+			result = append(result, connection)
+		}
+	}
+	best := make([]time.Time, len(r.timetable.graph.vertices))
+	for _, departure := range r.departures(walking, from, to) {
+		rounds := r.search(s.id, ta.id, departure, departure.Add(r.timetable.horizon), -1, best)
+		last := rounds[len(rounds)-1]
+		if !last[ta.id].reached || (last[ta.id].run == nil && last[ta.id].fromRound == 0) {
+			// the target is not reached earlier than before, or only on foot
+			continue
+		}
+		// the connection departs after to if the runs departing until to do not arrive earlier than later runs
+		if connection := createConnection(r.path(rounds, len(rounds)-1, ta.id)); connection != nil && !connection.Legs[0].Departure.After(to) {
+			result = append(result, connection)
+		}
+	}
+	result = paretoSet(result)
+	if len(result) == 0 {
+		return nil, ErrNoConnection
+	}
+	return result, nil
+}
+
+// departures returns the times between from and to (both inclusive) at which a passenger must leave the source in
+// order to catch a run at the source or at a stop reachable on foot. The walking labels contain the stops reached
+// on foot when leaving the source at from. The times are sorted from the latest to the earliest one.
+func (r *Raptor) departures(walking []raptorLabel, from time.Time, to time.Time) []time.Time {
+	unique := make(map[time.Time]bool)
+	result := make([]time.Time, 0, 0)
+	for id, l := range walking {
+		if !l.reached {
+			continue
+		}
+		walk := l.arrival.Sub(from)
+		for _, routeStop := range r.routesAt[id] {
+			route := routeStop.route
+			first := time.Date(from.Year(), from.Month(), from.Day()-route.daysBack, 0, 0, 0, 0, from.Location())
+			for day := first; !day.After(to); day = day.AddDate(0, 0, 1) {
+				for _, run := range route.runs {
+					departure := day.Add(run.departures[routeStop.index] - walk)
+					if departure.Before(from) || departure.After(to) || unique[departure] || !run.events[routeStop.index].runsOn(day) {
+						continue
+					}
+					unique[departure] = true
+					result = append(result, departure)
+				}
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].After(result[j])
+	})
+	return result
+}
+
+// paretoSet removes all connections which are dominated by another connection: a connection is
+// dominated if another connection departs not earlier and arrives not later. Of equal connections,
+// the first one is kept. The result is sorted by departure.
+func paretoSet(connections []*Connection) []*Connection {
+	sorted := make([]*Connection, len(connections))
+	copy(sorted, connections)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Legs[0].Departure.Equal(sorted[j].Legs[0].Departure) {
+			return sorted[i].Arrival.Before(sorted[j].Arrival)
+		}
+		return sorted[i].Legs[0].Departure.After(sorted[j].Legs[0].Departure)
+	})
+	result := make([]*Connection, 0, len(sorted))
+	for _, connection := range sorted {
+		// all connections in result depart not earlier than the current one
+		if len(result) != 0 && !connection.Arrival.Before(result[len(result)-1].Arrival) {
+			continue
+		}
+		result = append(result, connection)
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
@@ -0,0 +1,110 @@
+package routing
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestTimetable_QueryProfile(t *testing.T) {
+	network := createTestNetwork()
+	footpath := Footpath{From: network.docksAE, To: network.docksFG, Duration: 4 * time.Minute}
+	timetable := NewTimetable(network.stops(), WithFootpaths(footpath))
+
+	t.Run("with change", func(t *testing.T) {
+		profile, err := timetable.QueryProfile(network.northEnd, network.chalet, date("10:00"), date("11:00"))
+		require.NoError(t, err)
+		departures := make([]time.Time, 0, len(profile))
+		arrivals := make([]time.Time, 0, len(profile))
+		for _, connection := range profile {
+			departures = append(departures, connection.Legs[0].Departure)
+			arrivals = append(arrivals, connection.Arrival)
+		}
+		assert.Equal(t, []time.Time{date("10:00"), date("10:20"), date("10:40"), date("11:00")}, departures, "departures are wrong")
+		assert.Equal(t, []time.Time{date("10:13"), date("10:33"), date("10:53"), date("11:13")}, arrivals, "arrivals are wrong")
+	})
+	t.Run("optimal", func(t *testing.T) {
+		for _, source := range network.stops() {
+			for _, target := range network.stops() {
+				profile, err := timetable.QueryProfile(source, target, date("9:30"), date("12:30"))
+				if err == ErrNoConnection {
+					continue
+				}
+				require.NoError(t, err)
+				for i, connection := range profile {
+					departure := connection.Legs[0].Departure
+					assert.False(t, departure.Before(date("9:30")) || departure.After(date("12:30")), "%s -> %s: departure %v is outside of the window", source.Id, target.Id, departure)
+					if i > 0 {
+						assert.True(t, departure.After(profile[i-1].Legs[0].Departure), "%s -> %s: departures must increase", source.Id, target.Id)
+						assert.True(t, connection.Arrival.After(profile[i-1].Arrival), "%s -> %s: arrivals must increase", source.Id, target.Id)
+					}
+					earliest, err := timetable.Query(source, target, departure)
+					require.NoError(t, err)
+					assert.Equal(t, earliest.Arrival, connection.Arrival, "%s -> %s: connection at %v is not the fastest", source.Id, target.Id, departure)
+				}
+			}
+		}
+	})
+	t.Run("grid network", func(t *testing.T) {
+		grid := createGridNetwork(5, 10)
+		timetable := NewTimetable(flattenGrid(grid))
+		raptor := NewRaptor(&timetable)
+		for _, source := range flattenGrid(grid) {
+			for _, target := range flattenGrid(grid) {
+				if source == target {
+					continue
+				}
+				profile, err := timetable.QueryProfile(source, target, date("8:00"), date("9:00"))
+				require.NoError(t, err)
+				next := 0
+				for start := date("8:00"); !start.After(date("9:00")); start = start.Add(time.Minute) {
+					for next < len(profile) && profile[next].Legs[0].Departure.Before(start) {
+						next++
+					}
+					earliest, err := raptor.Query(source, target, start)
+					require.NoError(t, err)
+					if next == len(profile) {
+						// a connection departing after the window arrives at the same time
+						later, err := raptor.Query(source, target, date("9:01"))
+						require.NoError(t, err)
+						assert.Equal(t, earliest.Arrival, later.Arrival, "%s -> %s: profile misses the connection at %v", source.Id, target.Id, start)
+						continue
+					}
+					assert.Equal(t, earliest.Arrival, profile[next].Arrival, "%s -> %s: profile is wrong at %v", source.Id, target.Id, start)
+				}
+			}
+		}
+	})
+	t.Run("walking", func(t *testing.T) {
+		profile, err := timetable.QueryProfile(network.docksAE, network.docksFG, date("10:00"), date("11:00"))
+		require.NoError(t, err)
+		require.Equal(t, 1, len(profile), "walking is possible at any time")
+		assert.Equal(t, date("10:04"), profile[0].Arrival, "arrival is wrong")
+	})
+	t.Run("no connection", func(t *testing.T) {
+		profile, err := timetable.QueryProfile(network.northEnd, network.airport, date("21:00"), date("23:00"))
+		assert.Equal(t, ErrNoConnection, err, "there is no connection in the window")
+		assert.Nil(t, profile, "there is no connection in the window")
+	})
+	t.Run("unknown stop", func(t *testing.T) {
+		profile, err := timetable.QueryProfile(NewStop("XY", "Unknown"), network.airport, date("10:00"), date("11:00"))
+		assert.True(t, errors.Is(err, ErrUnknownStop), "error should be ErrUnknownStop")
+		assert.Nil(t, profile, "no profile should be returned")
+	})
+}
+
+func Test_paretoSet(t *testing.T) {
+	connection := func(departure string, arrival string) *Connection {
+		return &Connection{Arrival: date(arrival), Legs: []Leg{{Departure: date(departure)}}}
+	}
+	c1 := connection("10:00", "10:30")
+	c2 := connection("10:05", "10:30")
+	c3 := connection("10:10", "10:45")
+	c4 := connection("10:10", "10:40")
+	c5 := connection("10:20", "10:50")
+	c6 := connection("10:15", "10:55")
+	assert.Equal(t, []*Connection{c2, c4, c5}, paretoSet([]*Connection{c1, c2, c3, c4, c5, c6}), "dominated connections must be removed")
+	assert.Equal(t, []*Connection{}, paretoSet([]*Connection{}), "empty set")
+}
@@ -0,0 +1,578 @@
+package routing
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Router computes the fastest connection between two stops. Both the Timetable, which uses
+// a time-dependent Dijkstra search, and Raptor implement it, thus they can be exchanged and their
+// answers can be compared.
+type Router interface {
+	Query(source *Stop, target *Stop, start time.Time) (*Connection, error)
+}
+
+var _ Router = (*Timetable)(nil)
+var _ Router = (*Raptor)(nil)
+
+// Raptor is a router which implements the round-based public transit routing algorithm (RAPTOR).
+// Instead of a graph, it works on routes: all runs of vehicles which serve the same sequence of
+// stops without overtaking each other form a route. Round k computes the earliest arrivals using
+// k vehicles by scanning every route once which serves a stop improved in the previous round.
+//
+// Raptor uses the stops, footpaths, transfer policy, and search horizon of its timetable. Its connections
+// arrive at the same time as the connections of Query of the timetable or even earlier: if a stop is reached
+// at the same time by two vehicles, the Dijkstra search keeps only one of them, which may be the one that requires a
+// transfer, whereas RAPTOR continues with every vehicle along its route.
+type Raptor struct {
+	timetable *Timetable
+	routes    []*raptorRoute
+	// routesAt contains the routes departing at a vertex, routesTo the routes arriving at a vertex,
+	// both are indexed by the id of the vertex
+	routesAt    [][]routeStop
+	routesTo    [][]routeStop
+	footpaths   [][]*Footpath
+	footpathsTo [][]*Footpath
+	invalid     error
+}
+
+// raptorRoute is a sequence of stops, given by the ids of their vertices, and the runs of the vehicles serving them.
+// The runs are sorted by their departures, which is the same order at every stop.
+type raptorRoute struct {
+	id       int
+	stops    []int
+	runs     []*raptorRun
+	daysBack int
+}
+
+// raptorRun is the run of a vehicle along the stops of its route. The event i departs at stop i,
+// departures and arrivals contain the offsets relative to the service day at every stop.
+type raptorRun struct {
+	events     []*Event
+	departures []time.Duration
+	arrivals   []time.Duration
+}
+
+type routeStop struct {
+	route *raptorRoute
+	index int
+}
+
+// raptorLabel describes the earliest arrival at a stop within a round and how the stop was reached:
+// either with a run, boarded at the stop board of the route on the given service day, or with a footpath.
+// The stop reached before is from, its label is taken from the round fromRound.
+type raptorLabel struct {
+	reached   bool
+	arrival   time.Time
+	departure time.Time
+	event     *Event
+	route     *raptorRoute
+	run       *raptorRun
+	day       time.Time
+	board     int
+	alight    int
+	footpath  *Footpath
+	from      int
+	fromRound int
+}
+
+// NewRaptor creates the RAPTOR router of the timetable by grouping its runs into routes.
+// If the timetable contains invalid times, then the queries of the router return ErrInvalidTime.
+func NewRaptor(timetable *Timetable) *Raptor {
+	vertices := timetable.graph.vertices
+	result := &Raptor{timetable: timetable, routesAt: make([][]routeStop, len(vertices)), routesTo: make([][]routeStop, len(vertices)),
+		footpaths: make([][]*Footpath, len(vertices)), footpathsTo: make([][]*Footpath, len(vertices))}
+	for i := range timetable.footpaths {
+		footpath := &timetable.footpaths[i]
+		if timetable.contains(footpath.From) && timetable.contains(footpath.To) {
+			from := timetable.stops[footpath.From.Id].id
+			to := timetable.stops[footpath.To.Id].id
+			result.footpaths[from] = append(result.footpaths[from], footpath)
+			result.footpathsTo[to] = append(result.footpathsTo[to], footpath)
+		}
+	}
+	heads, err := timetable.runs()
+	if timetable.invalid != nil || err != nil {
+		result.invalid = timetable.invalid
+		if result.invalid == nil {
+			result.invalid = err
+		}
+		return result
+	}
+	patterns := make(map[string][]*raptorRun)
+	stops := make(map[string][]int)
+	keys := make([]string, 0, 0)
+	for _, c := range heads {
+		run := &raptorRun{arrivals: []time.Duration{c.offset}}
+		ids := []int{timetable.stops[c.stop.Id].id}
+		for ; c != nil; c = c.next {
+			run.events = append(run.events, c.event)
+			run.departures = append(run.departures, c.offset)
+			run.arrivals = append(run.arrivals, c.arrival)
+			ids = append(ids, timetable.stops[c.event.NextStop.Id].id)
+		}
+		run.departures = append(run.departures, run.arrivals[len(run.arrivals)-1])
+		key := fmt.Sprint(ids)
+		if _, ok := patterns[key]; !ok {
+			keys = append(keys, key)
+			stops[key] = ids
+		}
+		patterns[key] = append(patterns[key], run)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		runs := patterns[key]
+		sort.SliceStable(runs, func(i, j int) bool {
+			return runs[i].departures[0] < runs[j].departures[0]
+		})
+		routes := make([]*raptorRoute, 0, 0)
+		for _, run := range runs {
+			var route *raptorRoute
+			for _, candidate := range routes {
+				if !run.overtakes(candidate.runs[len(candidate.runs)-1]) {
+					route = candidate
+					break
+				}
+			}
+			if route == nil {
+				route = &raptorRoute{id: len(result.routes) + len(routes), stops: stops[key]}
+				routes = append(routes, route)
+			}
+			route.runs = append(route.runs, run)
+			if days := int(run.departures[len(run.departures)-2] / (24 * time.Hour)); days > route.daysBack {
+				route.daysBack = days
+			}
+		}
+		for _, route := range routes {
+			for i, id := range route.stops[:len(route.stops)-1] {
+				result.routesAt[id] = append(result.routesAt[id], routeStop{route: route, index: i})
+			}
+			for i, id := range route.stops[1:] {
+				result.routesTo[id] = append(result.routesTo[id], routeStop{route: route, index: i + 1})
+			}
+		}
+		result.routes = append(result.routes, routes...)
+	}
+	return result
+}
+
+// engines contains the routers which are created on demand for the queries of a timetable. It is shared
+// by all copies of the timetable, thus every router is created only once.
+type engines struct {
+	once   sync.Once
+	raptor *Raptor
+}
+
+// raptor returns the RAPTOR router of the timetable, which is created by the first call.
+func (t *Timetable) raptor() *Raptor {
+	t.engines.once.Do(func() {
+		t.engines.raptor = NewRaptor(t)
+	})
+	return t.engines.raptor
+}
+
+// overtakes returns true if the run departs or arrives at any stop earlier than the other run,
+// which departs not later at the first stop.
+func (r *raptorRun) overtakes(other *raptorRun) bool {
+	for i := range r.departures {
+		if r.departures[i] < other.departures[i] || r.arrivals[i] < other.arrivals[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// Query computes the fastest route between source and target with the specified start time.
+// The errors are the same as the errors of Query of the timetable.
+func (r *Raptor) Query(source *Stop, target *Stop, start time.Time) (*Connection, error) {
+	s, err := r.timetable.vertex(source, "source")
+	if err != nil {
+		return nil, err
+	}
+	ta, err := r.timetable.vertex(target, "target")
+	if err != nil {
+		return nil, err
+	}
+	if r.invalid != nil {
+		return nil, r.invalid
+	}
+	rounds := r.search(s.id, ta.id, start, start.Add(r.timetable.horizon), -1, nil)
+	last := rounds[len(rounds)-1]
+	if !last[ta.id].reached {
+		return nil, ErrNoConnection
+	}
+	connection := createConnection(r.path(rounds, len(rounds)-1, ta.id))
+	if connection == nil {
+		return nil, ErrNoConnection
+	}
+	return connection, nil
+}
+
+// search runs the rounds until no arrival improves anymore or maxRounds rounds using vehicles are done, if maxRounds
+// is not negative. It returns the labels of all rounds, round 0 contains the stops reached on foot.
+// Every round starts with a copy of the labels of the previous round. Arrivals which are not earlier
+// than the best arrival at t are discarded, because they cannot improve it.
+//
+// Best contains the earliest arrivals at the stops known so far, indexed by the ids of their vertices, and is updated
+// by the search. Arrivals which are not earlier are discarded, too. If best is nil, then no arrivals are known.
+func (r *Raptor) search(s int, t int, start time.Time, deadline time.Time, maxRounds int, best []time.Time) [][]raptorLabel {
+	vertices := r.timetable.graph.vertices
+	if best == nil {
+		best = make([]time.Time, len(vertices))
+	}
+	improves := func(id int, arrival time.Time) bool {
+		return (best[id] == time.Time{} || arrival.Before(best[id])) && (best[t] == time.Time{} || arrival.Before(best[t]))
+	}
+	labels := make([]raptorLabel, len(vertices))
+	labels[s] = raptorLabel{reached: true, arrival: start}
+	best[s] = start
+	marked := map[int]bool{s: true}
+	r.walk(labels, 0, marked, best, improves)
+	rounds := [][]raptorLabel{labels}
+	for round := 1; len(marked) != 0 && (maxRounds < 0 || round <= maxRounds); round++ {
+		previous := labels
+		labels = make([]raptorLabel, len(vertices))
+		copy(labels, previous)
+		// the first marked stop of every route, or -1 if the route does not serve a marked stop
+		queue := make([]int, len(r.routes))
+		for i := range queue {
+			queue[i] = -1
+		}
+		for id := range marked {
+			for _, routeStop := range r.routesAt[id] {
+				if index := queue[routeStop.route.id]; index < 0 || routeStop.index < index {
+					queue[routeStop.route.id] = routeStop.index
+				}
+			}
+		}
+		// runs can only be caught earlier at stops which improved in the previous round
+		improved := marked
+		marked = make(map[int]bool)
+		for _, route := range r.routes {
+			index := queue[route.id]
+			if index < 0 {
+				continue
+			}
+			var run *raptorRun
+			var day time.Time
+			board := 0
+			for i := index; i < len(route.stops); i++ {
+				id := route.stops[i]
+				if run != nil {
+					arrival := day.Add(run.arrivals[i])
+					if improves(id, arrival) {
+						labels[id] = raptorLabel{reached: true, arrival: arrival, departure: day.Add(run.departures[i-1]), event: run.events[i-1],
+							route: route, run: run, day: day, board: board, alight: i, from: route.stops[board], fromRound: round - 1}
+						best[id] = arrival
+						marked[id] = true
+					}
+					if day.Add(run.departures[i]).After(deadline) {
+						// like boarding, staying in the vehicle is not possible after the deadline
+						run = nil
+					}
+				}
+				from := previous[id]
+				if !improved[id] || i == len(route.stops)-1 || (run != nil && from.arrival.After(day.Add(run.departures[i]))) {
+					continue
+				}
+				if candidate, candidateDay, ok := r.earliestRun(route, i, from, deadline); ok {
+					if run == nil || candidateDay.Add(candidate.departures[i]).Before(day.Add(run.departures[i])) {
+						run, day, board = candidate, candidateDay, i
+					}
+				}
+			}
+		}
+		r.walk(labels, round, marked, best, improves)
+		rounds = append(rounds, labels)
+	}
+	return rounds
+}
+
+// earliestRun finds the run of the route departing first at the stop with the given index which can be
+// reached from the label in consideration of the transfer time. Runs of previous and following service days
+// are considered, but they must not depart after the deadline.
+func (r *Raptor) earliestRun(route *raptorRoute, index int, from raptorLabel, deadline time.Time) (*raptorRun, time.Time, bool) {
+	stop := r.timetable.graph.vertices[route.stops[index]].data
+	var best *raptorRun
+	var bestDay time.Time
+	var bestDeparture time.Time
+	t := from.arrival
+	day := time.Date(t.Year(), t.Month(), t.Day()-route.daysBack, 0, 0, 0, 0, t.Location())
+	for ; !day.After(deadline); day = day.AddDate(0, 0, 1) {
+		if best != nil && !day.Before(bestDeparture) {
+			// the runs of this and all following days depart later than the best one found so far
+			break
+		}
+		earliest := t.Sub(day)
+		latest := deadline.Sub(day)
+		first := sort.Search(len(route.runs), func(i int) bool {
+			return route.runs[i].departures[index] >= earliest
+		})
+		for _, candidate := range route.runs[first:] {
+			departure := candidate.departures[index]
+			if departure > latest || (best != nil && !day.Add(departure).Before(bestDeparture)) {
+				break
+			}
+			switchTime := r.timetable.policy.eventTransferTime(stop, from.event, candidate.events[index])
+			if departure < earliest+switchTime || !candidate.events[index].runsOn(day) {
+				continue
+			}
+			best, bestDay, bestDeparture = candidate, day, day.Add(departure)
+			break
+		}
+	}
+	return best, bestDay, best != nil
+}
+
+// walk uses the footpaths starting at the marked stops in order to improve the labels of the round.
+// Stops reached on foot are marked, too, and footpaths may be chained.
+func (r *Raptor) walk(labels []raptorLabel, round int, marked map[int]bool, best []time.Time, improves func(int, time.Time) bool) {
+	queue := make([]int, 0, len(marked))
+	for id := range marked {
+		queue = append(queue, id)
+	}
+	sort.Ints(queue)
+	for len(queue) != 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, footpath := range r.footpaths[id] {
+			target := r.timetable.stops[footpath.To.Id].id
+			departure := labels[id].arrival
+			arrival := departure.Add(footpath.Duration)
+			if !improves(target, arrival) {
+				continue
+			}
+			labels[target] = raptorLabel{reached: true, arrival: arrival, departure: departure, footpath: footpath, from: id, fromRound: round}
+			best[target] = arrival
+			marked[target] = true
+			queue = append(queue, target)
+		}
+	}
+}
+
+// path converts the labels leading to the stop in the given round into a path of the graph.
+func (r *Raptor) path(rounds [][]raptorLabel, round int, id int) []*label {
+	vertices := r.timetable.graph.vertices
+	l := rounds[round][id]
+	if l.run == nil && l.footpath == nil {
+		return []*label{{vertex: vertices[id], weight: l.arrival}}
+	}
+	result := r.path(rounds, l.fromRound, l.from)
+	if l.footpath != nil {
+		return append(result, &label{vertex: vertices[id], footpath: l.footpath, departure: l.departure, weight: l.arrival, predecessor: result[len(result)-1]})
+	}
+	for i := l.board + 1; i <= l.alight; i++ {
+		next := &label{vertex: vertices[l.route.stops[i]], event: l.run.events[i-1], departure: l.day.Add(l.run.departures[i-1]),
+			weight: l.day.Add(l.run.arrivals[i]), predecessor: result[len(result)-1]}
+		result = append(result, next)
+	}
+	return result
+}
+
+// latestLabel describes the latest departure at a stop within a round of a backward search and how the target
+// is reached from there: either with a run, boarded at the stop board of the route on the given service day and left
+// at the stop alight, or with a footpath. Event is the event boarded at the stop, it is nil if the passenger walks.
+// Arrival is the arrival at the next stop to, whose label is taken from the round toRound.
+type latestLabel struct {
+	reached   bool
+	departure time.Time
+	arrival   time.Time
+	event     *Event
+	route     *raptorRoute
+	run       *raptorRun
+	day       time.Time
+	board     int
+	alight    int
+	footpath  *Footpath
+	to        int
+	toRound   int
+}
+
+// QueryArriveBy computes the route between source and target which departs as late as possible
+// and arrives at the target not after the deadline. Departures earlier than the deadline minus the search horizon
+// are not considered. The errors are the same as the errors of Query of the timetable.
+func (r *Raptor) QueryArriveBy(source *Stop, target *Stop, deadline time.Time) (*Connection, error) {
+	s, err := r.timetable.vertex(source, "source")
+	if err != nil {
+		return nil, err
+	}
+	ta, err := r.timetable.vertex(target, "target")
+	if err != nil {
+		return nil, err
+	}
+	if r.invalid != nil {
+		return nil, r.invalid
+	}
+	rounds := r.searchBackward(s.id, ta.id, deadline, deadline.Add(-r.timetable.horizon))
+	last := rounds[len(rounds)-1]
+	if !last[s.id].reached {
+		return nil, ErrNoConnection
+	}
+	connection := createConnection(r.latestPath(rounds, len(rounds)-1, s.id))
+	if connection == nil {
+		return nil, ErrNoConnection
+	}
+	return connection, nil
+}
+
+// searchBackward runs the rounds backwards in time until no departure improves anymore: round k contains the latest
+// departures at the stops which reach t not after the deadline using at most k vehicles, round 0 contains the stops
+// which reach t on foot. Every round starts with a copy of the labels of the previous round. Departures before the
+// limit are not used. Departures which are not later than the best departure at s are discarded, because they cannot improve it.
+func (r *Raptor) searchBackward(s int, t int, deadline time.Time, limit time.Time) [][]latestLabel {
+	vertices := r.timetable.graph.vertices
+	best := make([]time.Time, len(vertices))
+	improves := func(id int, departure time.Time) bool {
+		return (best[id] == time.Time{} || departure.After(best[id])) && (best[s] == time.Time{} || departure.After(best[s]))
+	}
+	labels := make([]latestLabel, len(vertices))
+	labels[t] = latestLabel{reached: true, departure: deadline}
+	best[t] = deadline
+	marked := map[int]bool{t: true}
+	r.walkBackward(labels, 0, marked, best, improves)
+	rounds := [][]latestLabel{labels}
+	for round := 1; len(marked) != 0; round++ {
+		previous := labels
+		labels = make([]latestLabel, len(vertices))
+		copy(labels, previous)
+		// the last marked stop of every route, or -1 if the route does not serve a marked stop
+		queue := make([]int, len(r.routes))
+		for i := range queue {
+			queue[i] = -1
+		}
+		for id := range marked {
+			for _, routeStop := range r.routesTo[id] {
+				if routeStop.index > queue[routeStop.route.id] {
+					queue[routeStop.route.id] = routeStop.index
+				}
+			}
+		}
+		// runs can only be left later at stops which improved in the previous round
+		improved := marked
+		marked = make(map[int]bool)
+		for _, route := range r.routes {
+			index := queue[route.id]
+			if index < 0 {
+				continue
+			}
+			var run *raptorRun
+			var day time.Time
+			alight := 0
+			for i := index; i >= 0; i-- {
+				id := route.stops[i]
+				if run != nil {
+					departure := day.Add(run.departures[i])
+					if departure.Before(limit) {
+						// like alighting, boarding the vehicle is not possible before the limit
+						run = nil
+					} else if improves(id, departure) {
+						labels[id] = latestLabel{reached: true, departure: departure, arrival: day.Add(run.arrivals[alight]), event: run.events[i],
+							route: route, run: run, day: day, board: i, alight: alight, to: route.stops[alight], toRound: round - 1}
+						best[id] = departure
+						marked[id] = true
+					}
+				}
+				to := previous[id]
+				if !improved[id] || i == 0 || (run != nil && to.departure.Before(day.Add(run.arrivals[i]))) {
+					continue
+				}
+				if candidate, candidateDay, ok := r.latestRun(route, i, to, limit); ok {
+					if run == nil || candidateDay.Add(candidate.arrivals[i]).After(day.Add(run.arrivals[i])) {
+						run, day, alight = candidate, candidateDay, i
+					}
+				}
+			}
+		}
+		r.walkBackward(labels, round, marked, best, improves)
+		rounds = append(rounds, labels)
+	}
+	return rounds
+}
+
+// latestRun finds the run of the route arriving last at the stop with the given index from which the label
+// can be reached in consideration of the transfer time. Runs of previous service days are considered, but they
+// must not arrive before the limit.
+func (r *Raptor) latestRun(route *raptorRoute, index int, to latestLabel, limit time.Time) (*raptorRun, time.Time, bool) {
+	stop := r.timetable.graph.vertices[route.stops[index]].data
+	// the runs of a route do not overtake each other, thus the last run arrives last at every stop
+	latestArrival := route.runs[len(route.runs)-1].arrivals[index]
+	var best *raptorRun
+	var bestDay time.Time
+	var bestArrival time.Time
+	t := to.departure
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	firstDay := time.Date(limit.Year(), limit.Month(), limit.Day()-int(latestArrival/(24*time.Hour)), 0, 0, 0, 0, limit.Location())
+	for ; !day.Before(firstDay); day = day.AddDate(0, 0, -1) {
+		if best != nil && !day.Add(latestArrival).After(bestArrival) {
+			// the runs of this and all previous days arrive earlier than the best one found so far
+			break
+		}
+		latest := t.Sub(day)
+		earliest := limit.Sub(day)
+		last := sort.Search(len(route.runs), func(i int) bool {
+			return route.runs[i].arrivals[index] > latest
+		})
+		for i := last - 1; i >= 0; i-- {
+			candidate := route.runs[i]
+			arrival := candidate.arrivals[index]
+			if arrival < earliest || (best != nil && !day.Add(arrival).After(bestArrival)) {
+				break
+			}
+			switchTime := r.timetable.policy.eventTransferTime(stop, candidate.events[index-1], to.event)
+			if arrival > latest-switchTime || !candidate.events[index-1].runsOn(day) {
+				continue
+			}
+			best, bestDay, bestArrival = candidate, day, day.Add(arrival)
+			break
+		}
+	}
+	return best, bestDay, best != nil
+}
+
+// walkBackward uses the footpaths ending at the marked stops in order to improve the labels of the round.
+// The passengers start walking as late as possible. Stops reached on foot are marked, too, and footpaths may be chained.
+func (r *Raptor) walkBackward(labels []latestLabel, round int, marked map[int]bool, best []time.Time, improves func(int, time.Time) bool) {
+	queue := make([]int, 0, len(marked))
+	for id := range marked {
+		queue = append(queue, id)
+	}
+	sort.Ints(queue)
+	for len(queue) != 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, footpath := range r.footpathsTo[id] {
+			source := r.timetable.stops[footpath.From.Id].id
+			arrival := labels[id].departure
+			departure := arrival.Add(-footpath.Duration)
+			if !improves(source, departure) {
+				continue
+			}
+			labels[source] = latestLabel{reached: true, departure: departure, arrival: arrival, footpath: footpath, to: id, toRound: round}
+			best[source] = departure
+			marked[source] = true
+			queue = append(queue, source)
+		}
+	}
+}
+
+// latestPath converts the labels of a backward search leading from the stop in the given round to the target
+// into a path of the graph. Like the paths of forward searches, the path starts at the stop.
+func (r *Raptor) latestPath(rounds [][]latestLabel, round int, id int) []*label {
+	vertices := r.timetable.graph.vertices
+	l := rounds[round][id]
+	result := []*label{{vertex: vertices[id], weight: l.departure}}
+	for l.run != nil || l.footpath != nil {
+		if l.footpath != nil {
+			result = append(result, &label{vertex: vertices[l.to], footpath: l.footpath, departure: l.departure, weight: l.arrival, predecessor: result[len(result)-1]})
+		}
+		for i := l.board + 1; l.run != nil && i <= l.alight; i++ {
+			next := &label{vertex: vertices[l.route.stops[i]], event: l.run.events[i-1], departure: l.day.Add(l.run.departures[i-1]),
+				weight: l.day.Add(l.run.arrivals[i]), predecessor: result[len(result)-1]}
+			result = append(result, next)
+		}
+		l = rounds[l.toRound][l.to]
+	}
+	return result
+}
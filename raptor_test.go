@@ -0,0 +1,109 @@
+package routing
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRaptor_Query(t *testing.T) {
+	testRouter(t, func(timetable *Timetable) Router {
+		return NewRaptor(timetable)
+	})
+}
+
+// testRouter compares the router created by newRouter with Query of the timetable and checks
+// the behavior every router must share with the timetable.
+func testRouter(t *testing.T, newRouter func(*Timetable) Router) {
+	network := createTestNetwork()
+	// if exact is false, the router may find earlier arrivals, see the documentation of Raptor
+	crossCheck := func(t *testing.T, exact bool, stops []*Stop, starts []time.Time, options ...Option) {
+		timetable := NewTimetable(stops, options...)
+		router := newRouter(&timetable)
+		for _, r := range []Router{&timetable, router} {
+			_, err := r.Query(stops[0], NewStop("XY", "Unknown"), starts[0])
+			assert.True(t, errors.Is(err, ErrUnknownStop), "error should be ErrUnknownStop")
+		}
+		for _, source := range stops {
+			for _, target := range stops {
+				for _, start := range starts {
+					expected, err := timetable.Query(source, target, start)
+					actual, routerErr := router.Query(source, target, start)
+					require.Equal(t, err, routerErr, "errors differ for %s -> %s at %v", source.Id, target.Id, start)
+					if err != nil {
+						continue
+					}
+					if exact {
+						assert.Equal(t, expected.Arrival, actual.Arrival, "%s -> %s at %v: arrival is wrong", source.Id, target.Id, start)
+					} else {
+						assert.False(t, actual.Arrival.After(expected.Arrival), "%s -> %s at %v: arrival is later", source.Id, target.Id, start)
+					}
+					assert.Equal(t, start, actual.Legs[0].Departure.Add(-actual.Legs[0].Wait), "%s -> %s at %v: connection must start at the start time", source.Id, target.Id, start)
+					for i := 1; i < len(actual.Legs); i++ {
+						assert.False(t, actual.Legs[i].Departure.Before(actual.Legs[i-1].Arrival), "%s -> %s at %v: leg %d departs before the previous leg arrives", source.Id, target.Id, start, i)
+					}
+				}
+			}
+		}
+	}
+	starts := []time.Time{date("7:50"), date("8:04"), date("10:25"), date("14:34"), date("19:58")}
+
+	t.Run("test network", func(t *testing.T) {
+		crossCheck(t, true, network.stops(), starts)
+	})
+	t.Run("footpaths", func(t *testing.T) {
+		footpaths := []Footpath{
+			{From: network.docksAE, To: network.docksFG, Duration: 4 * time.Minute},
+			{From: network.docksFG, To: network.marketPlace, Duration: 6 * time.Minute},
+			{From: network.northEnd, To: network.northAvenue, Duration: 9 * time.Minute},
+		}
+		crossCheck(t, true, network.stops(), starts, WithFootpaths(footpaths...))
+	})
+	t.Run("transfer policies", func(t *testing.T) {
+		crossCheck(t, true, network.stops(), starts, WithTransferPolicy(NewTransferPolicy(0)))
+		policy := NewTransferPolicy(DefaultTransferTime)
+		policy.SetLineTransferTime(network.redLine, network.blueLine, 10*time.Minute)
+		policy.SetStaySeated(network.redLine, network.blueLine)
+		policy.SetStopTransferTime(network.mainStation, 7*time.Minute)
+		crossCheck(t, true, network.stops(), starts, WithTransferPolicy(policy))
+	})
+	t.Run("grid network", func(t *testing.T) {
+		grid := createGridNetwork(5, 10)
+		crossCheck(t, false, flattenGrid(grid), []time.Time{date("8:00"), date("9:13")})
+	})
+	t.Run("same arrivals", func(t *testing.T) {
+		grid := createGridNetwork(5, 10)
+		timetable := NewTimetable(flattenGrid(grid))
+		connection, err := newRouter(&timetable).Query(grid[4][4], grid[3][1], date("8:00"))
+		require.NoError(t, err)
+		// 3-3 is reached at 8:12 both with R3W and C3N, the vehicle of R3W is continued
+		require.Equal(t, 2, len(connection.Legs), "number of legs in the connection")
+		assert.Equal(t, "R3W", connection.Legs[1].Line.Id, "line is wrong")
+		assert.Equal(t, date("8:16"), connection.Arrival, "time is wrong")
+	})
+	t.Run("overnight", func(t *testing.T) {
+		zoo := NewStop("ZO", "Zoo")
+		mall := NewStop("MA", "Mall")
+		court := NewStop("CO", "Court")
+		nightLine := &Line{Name: "N1", Id: "N1"}
+		weekdays := NewService("WD", time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday)
+		_, err := NewTrip("N1-2350", nightLine, []StopTime{{Stop: zoo, Departure: "23:55"}, {Stop: mall, Arrival: "24:20", Departure: "24:20"}, {Stop: court, Arrival: "25:10"}})
+		require.NoError(t, err)
+		morning, err := NewTrip("N1-0500", nightLine, []StopTime{{Stop: mall, Departure: "5:00"}, {Stop: court, Arrival: "5:30"}})
+		require.NoError(t, err)
+		morning.Service = weekdays
+		starts := []time.Time{date("00:10"), date("00:30"), date("23:50"), date("00:30").AddDate(0, 0, 2)}
+		crossCheck(t, true, []*Stop{zoo, mall, court}, starts)
+	})
+	t.Run("invalid time", func(t *testing.T) {
+		zoo := NewStop("ZO", "Zoo")
+		mall := NewStop("MA", "Mall")
+		zoo.Events = append(zoo.Events, Event{Departure: "8:6O", NextStop: mall, TravelTime: time.Minute})
+		timetable := NewTimetable([]*Stop{zoo, mall})
+		connection, err := newRouter(&timetable).Query(zoo, mall, date("8:00"))
+		assert.True(t, errors.Is(err, ErrInvalidTime), "error should be ErrInvalidTime")
+		assert.Nil(t, connection, "no connection should be returned")
+	})
+}
@@ -0,0 +1,73 @@
+package routing
+
+import "time"
+
+// Service describes on which days events take place. A service runs on all days whose weekday
+// is enabled in Weekdays (indexed by time.Weekday) and which lie between Start and End (both inclusive).
+// A zero Start or End means that the validity is not restricted in this direction.
+// Dates in Added and Removed are exceptions: the service always runs on added dates and never runs on removed dates.
+//
+// Only the year, month, and day of the dates are evaluated.
+type Service struct {
+	Id       string
+	Weekdays [7]bool
+	Start    time.Time
+	End      time.Time
+	Added    []time.Time
+	Removed  []time.Time
+}
+
+// NewService creates a new service which runs on the given weekdays without any other restriction.
+func NewService(id string, weekdays ...time.Weekday) *Service {
+	service := &Service{Id: id}
+	for _, weekday := range weekdays {
+		service.Weekdays[weekday] = true
+	}
+	return service
+}
+
+// RunsOn checks whether the service runs on the day of the given date.
+func (s *Service) RunsOn(date time.Time) bool {
+	day := dayOf(date)
+	for _, removed := range s.Removed {
+		if dayOf(removed) == day {
+			return false
+		}
+	}
+	for _, added := range s.Added {
+		if dayOf(added) == day {
+			return true
+		}
+	}
+	if !s.Start.IsZero() && day < dayOf(s.Start) {
+		return false
+	}
+	if !s.End.IsZero() && day > dayOf(s.End) {
+		return false
+	}
+	return s.Weekdays[date.Weekday()]
+}
+
+// dayOf converts the date into a number which is ordered like the days, e.g. 20201015.
+func dayOf(date time.Time) int {
+	return date.Year()*10000 + int(date.Month())*100 + date.Day()
+}
+
+// service returns the service of the event. If the event has no own service, then the service
+// of its trip is returned. If there is no service at all, then nil is returned, which means that
+// the event takes place every day.
+func (e *Event) service() *Service {
+	if e.Service != nil {
+		return e.Service
+	}
+	if e.Trip != nil {
+		return e.Trip.Service
+	}
+	return nil
+}
+
+// runsOn checks whether the event takes place on the day of the given date.
+func (e *Event) runsOn(date time.Time) bool {
+	service := e.service()
+	return service == nil || service.RunsOn(date)
+}
@@ -0,0 +1,79 @@
+package routing
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestService_RunsOn(t *testing.T) {
+	day := func(month time.Month, day int) time.Time {
+		return time.Date(2020, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	weekdays := NewService("WD", time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday)
+
+	t.Run("weekdays", func(t *testing.T) {
+		assert.True(t, weekdays.RunsOn(day(time.October, 15)), "service should run on Thursday")
+		assert.True(t, weekdays.RunsOn(day(time.October, 16).Add(23*time.Hour)), "time of the day should be ignored")
+		assert.False(t, weekdays.RunsOn(day(time.October, 17)), "service should not run on Saturday")
+		assert.False(t, weekdays.RunsOn(day(time.October, 18)), "service should not run on Sunday")
+	})
+	t.Run("validity", func(t *testing.T) {
+		service := *weekdays
+		service.Start = day(time.October, 13)
+		service.End = day(time.October, 15).Add(12 * time.Hour)
+		assert.False(t, service.RunsOn(day(time.October, 12)), "service should not run before start")
+		assert.True(t, service.RunsOn(day(time.October, 13)), "start should be inclusive")
+		assert.True(t, service.RunsOn(day(time.October, 15).Add(18*time.Hour)), "end should be inclusive")
+		assert.False(t, service.RunsOn(day(time.October, 16)), "service should not run after end")
+	})
+	t.Run("exceptions", func(t *testing.T) {
+		service := *weekdays
+		service.End = day(time.October, 31)
+		service.Added = []time.Time{day(time.October, 17), day(time.November, 2)}
+		service.Removed = []time.Time{day(time.October, 15)}
+		assert.False(t, service.RunsOn(day(time.October, 15)), "service should not run on removed date")
+		assert.True(t, service.RunsOn(day(time.October, 17)), "service should run on added date")
+		assert.True(t, service.RunsOn(day(time.November, 2)), "added date should be outside of validity, too")
+		assert.False(t, service.RunsOn(day(time.November, 3)), "service should not run after end")
+	})
+	t.Run("other location", func(t *testing.T) {
+		location := time.FixedZone("UTC+10", 10*60*60)
+		thursday := time.Date(2020, time.October, 15, 0, 0, 0, 0, location)
+		service := *weekdays
+		service.Start = thursday
+		assert.True(t, service.RunsOn(thursday), "day should be compared in the location of the date")
+	})
+}
+
+func TestTimetable_QueryServices(t *testing.T) {
+	zoo := NewStop("ZO", "Zoo")
+	mall := NewStop("MA", "Mall")
+	southBound := &Line{Name: "1 SouthBound", Id: "1"}
+	weekdays := NewService("WD", time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday)
+	weekend := NewService("WE", time.Saturday, time.Sunday)
+
+	_, err := NewTrip("1-0800", southBound, []StopTime{{Stop: zoo, Departure: "8:00"}, {Stop: mall, Arrival: "8:05"}})
+	require.NoError(t, err)
+	trip, err := NewTrip("1-0810", southBound, []StopTime{{Stop: zoo, Departure: "8:10"}, {Stop: mall, Arrival: "8:15"}})
+	require.NoError(t, err)
+	trip.Service = weekdays
+	zoo.Events = append(zoo.Events, Event{Departure: "8:20", Line: southBound, Service: weekend, NextStop: mall, TravelTime: 5 * time.Minute})
+	timetable := NewTimetable([]*Stop{zoo, mall})
+
+	thursday := time.Date(2020, time.October, 15, 8, 1, 0, 0, time.UTC)
+	connection, err := timetable.Query(zoo, mall, thursday)
+	require.NoError(t, err)
+	assert.Equal(t, trip, connection.Legs[0].Trip, "the trip running on weekdays should be used")
+
+	sunday := time.Date(2020, time.October, 18, 8, 1, 0, 0, time.UTC)
+	connection, err = timetable.Query(zoo, mall, sunday)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2020, time.October, 18, 8, 25, 0, 0, time.UTC), connection.Arrival, "the event running on weekends should be used")
+
+	weekdays.Removed = []time.Time{thursday}
+	connection, err = timetable.Query(zoo, mall, thursday)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2020, time.October, 16, 8, 5, 0, 0, time.UTC), connection.Arrival, "the connection of the next day should be used on the removed date")
+}
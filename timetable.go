@@ -10,7 +10,7 @@ import (
 
 // Time is a string data type that can be interpreted as simple time
 // (without date). The time string should always match the TimeRegex,
-// otherwise a panic may be risen.
+// otherwise ErrInvalidTime is returned when the time is used.
 //
 // Examples: 12:04, 14:34, 28:23 are all valid times
 type Time string
@@ -23,63 +23,177 @@ func CreateTime(hour, minute int) Time {
 // TimeRegex is used to validate time strings.
 var TimeRegex = regexp.MustCompile("^([0-9]+):?([0-5][0-9])$")
 
-func (t Time) interpret(date time.Time) time.Time {
+func (t Time) interpret(date time.Time) (time.Time, error) {
+	hour, minute, err := t.parse()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, date.Location()), nil
+}
+
+// offset returns the duration between the midnight of the day and the time.
+func (t Time) offset() (time.Duration, error) {
+	hour, minute, err := t.parse()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute, nil
+}
+
+func (t Time) parse() (int, int, error) {
 	submatch := TimeRegex.FindStringSubmatch(string(t))
 	if submatch == nil {
-		panic(fmt.Sprintf("the string \"%s\" does not match the required format", t))
+		return 0, 0, fmt.Errorf("the string \"%s\" is invalid: %w", t, ErrInvalidTime)
 	}
 	hour, _ := strconv.Atoi(submatch[1])
 	minute, _ := strconv.Atoi(submatch[2])
-	return time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, date.Location())
+	return hour, minute, nil
 }
 
 // Timetable contains all routing information in a public transport network.
-// Timetables should be created with the NewTimetable function.
+// Timetables should be created with the NewTimetable function. A timetable
+// must not be changed after its creation, but it can be queried concurrently.
 type Timetable struct {
-	stops map[string]*vertex
-	graph graph
+	stops         map[string]*vertex
+	graph         graph
+	policy        *TransferPolicy
+	footpaths     []Footpath
+	horizon       time.Duration
+	index         *stopIndex
+	walkingRadius float64
+	walkingSpeed  float64
+	invalid       error
+	engines       *engines
+}
+
+// Option configures optional aspects of a Timetable, see NewTimetable.
+type Option func(*Timetable)
+
+// WithTransferPolicy sets the policy which determines the minimum transfer times
+// between lines. If the option is not given, then DefaultTransferTime is used for
+// all transfers.
+func WithTransferPolicy(policy *TransferPolicy) Option {
+	return func(t *Timetable) {
+		t.policy = policy
+	}
+}
+
+// DefaultSearchHorizon is the search horizon of a timetable if no other horizon is configured.
+const DefaultSearchHorizon = 24 * time.Hour
+
+// WithSearchHorizon limits how far a query looks into the future: departures later than
+// the start time of the query plus the horizon are not considered. The horizon determines how many
+// service days are searched, thus large horizons make queries without connection slower.
+// If the option is not given, then DefaultSearchHorizon is used.
+func WithSearchHorizon(horizon time.Duration) Option {
+	return func(t *Timetable) {
+		t.horizon = horizon
+	}
 }
 
 // NewTimetable creates a new timetable containing the passed stops. The stops
 // contain all relevant information about the transport network (arrivals, departures, and lines).
-func NewTimetable(stops []*Stop) Timetable {
+// The stops are not validated, events pointing to stops which are not part of the timetable are ignored.
+// Use NewTimetableStrict to detect such problems.
+func NewTimetable(stops []*Stop, options ...Option) Timetable {
 	vertices := make([]*vertex, 0, len(stops))
 	vertexMap := make(map[string]*vertex)
-	for _, stop := range stops {
-		vertex := &vertex{data: stop}
+	for i, stop := range stops {
+		vertex := &vertex{data: stop, id: i}
 		vertexMap[stop.Id] = vertex
 		vertices = append(vertices, vertex)
 	}
-	t := Timetable{}
-	t.graph = graph{vertices: vertices}
-	return Timetable{graph: graph{vertices: vertices}, stops: vertexMap}
+	t := Timetable{graph: graph{vertices: vertices}, stops: vertexMap, policy: NewTransferPolicy(DefaultTransferTime), horizon: DefaultSearchHorizon,
+		walkingRadius: DefaultWalkingRadius, walkingSpeed: DefaultWalkingSpeed, engines: &engines{}}
+	for _, option := range options {
+		option(&t)
+	}
+	t.computeEdges()
+	t.index = newStopIndex(stops)
+	return t
+}
+
+// computeEdges creates the edges of the graph. If there are invalid departures, then
+// the affected edges are omitted and the first error is remembered in order to report it in queries.
+func (t *Timetable) computeEdges() {
+	for _, vertex := range t.graph.vertices {
+		edges, err := vertex.data.computeEdges(t.stops, t.policy)
+		if err != nil && t.invalid == nil {
+			t.invalid = err
+		}
+		vertex.neighbors = edges
+	}
+	for i := range t.footpaths {
+		footpath := &t.footpaths[i]
+		if t.contains(footpath.From) && t.contains(footpath.To) {
+			from := t.stops[footpath.From.Id]
+			from.neighbors = append(from.neighbors, edge{target: t.stops[footpath.To.Id], weight: footpath.weightFunction()})
+		}
+	}
 }
 
 // Query computes the fastest route between source and target with the specified start time.
-// If there is no connection, then nil is returned.
-func (t *Timetable) Query(source *Stop, target *Stop, start time.Time) *Connection {
-	for _, stop := range t.stops {
-		edges := stop.data.computeEdges(start, t.stops)
-		stop.neighbors = edges
+// Departures are interpreted on the day of the start time as well as on the previous and following
+// days, thus connections crossing midnight are found as long as they depart within the search horizon.
+// If source or target are not part of the timetable, then ErrUnknownStop is returned.
+// If a departure time of the timetable is malformed, then ErrInvalidTime is returned.
+// If there is no connection, then ErrNoConnection is returned.
+func (t *Timetable) Query(source *Stop, target *Stop, start time.Time) (*Connection, error) {
+	s, err := t.vertex(source, "source")
+	if err != nil {
+		return nil, err
 	}
-	s, ok := t.stops[source.Id]
-	if !ok {
-		panic(fmt.Sprintf("source \"%s\" not found in the timetable", source.Id))
+	ta, err := t.vertex(target, "target")
+	if err != nil {
+		return nil, err
+	}
+	if t.invalid != nil {
+		return nil, t.invalid
+	}
+	path := t.graph.shortestPath(s, ta, start, start.Add(t.horizon))
+	connection := createConnection(path)
+	if connection == nil {
+		return nil, ErrNoConnection
+	}
+	return connection, nil
+}
+
+// QueryArriveBy computes the route between source and target which departs as late as possible
+// and arrives at the target not after the deadline. Departures earlier than the deadline minus the search horizon
+// are not considered. The route is computed backwards in time by the RAPTOR router of the timetable, see
+// Raptor.QueryArriveBy. The errors are the same as the errors of Query.
+func (t *Timetable) QueryArriveBy(source *Stop, target *Stop, deadline time.Time) (*Connection, error) {
+	if _, err := t.vertex(source, "source"); err != nil {
+		return nil, err
+	}
+	if _, err := t.vertex(target, "target"); err != nil {
+		return nil, err
+	}
+	if t.invalid != nil {
+		return nil, t.invalid
 	}
-	ta, ok := t.stops[target.Id]
+	return t.raptor().QueryArriveBy(source, target, deadline)
+}
+
+func (t *Timetable) vertex(stop *Stop, role string) (*vertex, error) {
+	if stop == nil {
+		return nil, fmt.Errorf("%s is nil: %w", role, ErrUnknownStop)
+	}
+	result, ok := t.stops[stop.Id]
 	if !ok {
-		panic(fmt.Sprintf("target \"%s\" not found in the timetable", target.Id))
+		return nil, fmt.Errorf("%s \"%s\": %w", role, stop.Id, ErrUnknownStop)
 	}
-	path := t.graph.shortestPath(s, ta, start)
-	return createConnection(path)
+	return result, nil
 }
 
 // Stop is a physical stop where a public transport vehicle stops and lets
 // passengers enter and exit. The Id of the stop must be unique.
+// The Position of the stop is optional, it is needed for geographic queries like NearestStops.
 type Stop struct {
-	Id     string
-	Name   string
-	Events []Event
+	Id       string
+	Name     string
+	Position *Coordinate
+	Events   []Event
 }
 
 // NewStop creates a new stop with the given id and name and an empty events slice.
@@ -87,19 +201,35 @@ func NewStop(id, name string) *Stop {
 	return &Stop{Id: id, Name: name, Events: make([]Event, 0, 0)}
 }
 
-func (s *Stop) computeEdges(date time.Time, vertices map[string]*vertex) []edge {
+func (s *Stop) computeEdges(vertices map[string]*vertex, policy *TransferPolicy) ([]edge, error) {
 	eventGroups := s.groupEvents()
 	result := make([]edge, 0, 0)
+	var invalid error
 	for _, event := range eventGroups {
-		edge := edge{target: vertices[event[0].nextStop().Id], weight: event.weightFunction(date)}
-		result = append(result, edge)
+		departures, err := event.departures()
+		if err != nil {
+			if invalid == nil {
+				invalid = fmt.Errorf("departure at stop \"%s\": %w", s.Id, err)
+			}
+			continue
+		}
+		target, ok := vertices[event[0].nextStop().Id]
+		if !ok {
+			// the next stop is not part of the timetable, see Validate
+			continue
+		}
+		constrained := constrainedWeightFunction(s, departures, policy)
+		result = append(result, edge{target: target, weight: unconstrained(constrained), constrained: constrained})
 	}
-	return result
+	return result, invalid
 }
 
 func (s *Stop) groupEvents() map[string]eventGroup {
 	result := make(map[string]eventGroup)
 	for _, event := range s.Events {
+		if event.nextStop() == nil {
+			continue
+		}
 		list, ok := result[event.nextStop().Id]
 		if !ok {
 			list = make([]Event, 0, 0)
@@ -112,33 +242,87 @@ func (s *Stop) groupEvents() map[string]eventGroup {
 
 type eventGroup []Event
 
-func (e eventGroup) weightFunction(date time.Time) edgeWeight {
-	return func(t time.Time, currentLine *Line) (time.Duration, *Line, bool) {
-		arrivalMap := make(map[time.Time]Event)
-		arrivals := make([]time.Time, 0, len(e))
-		for _, event := range e {
-			switchTime := 0 * time.Minute
-			// if currentLine == nil, we are at the source station
-			if currentLine != nil && event.Line != currentLine {
-				switchTime = 5 * time.Minute
+// departure is an event whose departure time is parsed.
+type departure struct {
+	event   *Event
+	offset  time.Duration
+	arrival time.Duration
+}
+
+// departures parses the departures of the group and sorts them by their departure.
+func (e eventGroup) departures() ([]departure, error) {
+	departures := make([]departure, 0, len(e))
+	for i := range e {
+		event := &e[i]
+		offset, err := event.Departure.offset()
+		if err != nil {
+			return nil, err
+		}
+		departures = append(departures, departure{event: event, offset: offset, arrival: offset + event.durationToNextStop()})
+	}
+	sort.Slice(departures, func(i, j int) bool {
+		return departures[i].offset < departures[j].offset
+	})
+	return departures, nil
+}
+
+// unconstrained turns the constrained weight into a weight allowing all departures.
+func unconstrained(weight constrainedWeight) edgeWeight {
+	return func(deadline time.Time, t time.Time, currentEvent *Event) (hop, bool) {
+		return weight(deadline, t, currentEvent, nil)
+	}
+}
+
+// constrainedWeightFunction creates the weight function of an edge, which only uses the departures
+// whose events are allowed. The departures must be sorted by their departure, see eventGroup.departures,
+// such that the weight function can find the next departure with a binary search.
+func constrainedWeightFunction(stop *Stop, departures []departure, policy *TransferPolicy) constrainedWeight {
+	// departures of previous service days may still take place after midnight, e.g. 25:10
+	daysBack := 0
+	if len(departures) != 0 {
+		daysBack = int(departures[len(departures)-1].offset / (24 * time.Hour))
+	}
+	return func(deadline time.Time, t time.Time, currentEvent *Event, allowed func(*Event) bool) (hop, bool) {
+		var best *departure
+		var bestDay time.Time
+		day := time.Date(t.Year(), t.Month(), t.Day()-daysBack, 0, 0, 0, 0, t.Location())
+		for ; !day.After(deadline) && len(departures) != 0; day = day.AddDate(0, 0, 1) {
+			// offsets are relative to the midnight of the service day, so is the arrival of the best departure
+			var bound time.Duration
+			if best != nil {
+				bound = bestDay.Add(best.arrival).Sub(day)
+				if bound <= 0 {
+					// the departures of this and all following days arrive later than the best one found so far
+					break
+				}
 			}
-			departure := event.Departure.interpret(date)
-			switchFinished := t.Add(switchTime)
-			if departure.Equal(switchFinished) || departure.After(switchFinished) {
-				arrival := departure.Add(event.durationToNextStop())
-				arrivalMap[arrival] = event
-				arrivals = append(arrivals, arrival)
+			earliest := t.Sub(day)
+			latest := deadline.Sub(day)
+			first := sort.Search(len(departures), func(i int) bool {
+				return departures[i].offset >= earliest
+			})
+			for i := first; i < len(departures); i++ {
+				candidate := &departures[i]
+				if candidate.offset > latest || (best != nil && candidate.offset >= bound) {
+					// all following departures are beyond the horizon or arrive later than the best one found so far
+					break
+				}
+				// if currentEvent == nil, we are at the source station
+				switchTime := policy.eventTransferTime(stop, currentEvent, candidate.event)
+				if candidate.offset < earliest+switchTime || !candidate.event.runsOn(day) || (allowed != nil && !allowed(candidate.event)) {
+					continue
+				}
+				if best == nil || candidate.arrival < bound {
+					best = candidate
+					bestDay = day
+					bound = candidate.arrival
+				}
 			}
 		}
-		if len(arrivals) == 0 {
-			return 0 * time.Minute, nil, false
+		if best == nil {
+			return hop{}, false
 		}
-		sort.Slice(arrivals, func(i, j int) bool {
-			return arrivals[i].Before(arrivals[j])
-		})
-		event := arrivalMap[arrivals[0]]
-		arrival := arrivals[0]
-		return arrival.Sub(t), event.Line, true
+		return hop{event: best.event, departure: bestDay.Add(best.offset), arrival: bestDay.Add(best.arrival)}, true
 	}
 }
 
@@ -154,9 +338,16 @@ type Line struct {
 
 // Event describes the departure of a certain line's vehicle at a station. The segment property
 // points to the line segment that describes the journey to the next stop after the event's stop.
+// Trip is optional and references the vehicle run the event belongs to. If two events
+// both reference trips, then changing between them is only free of transfer time if the trips are the same.
+// Otherwise, events of the same line are considered to belong to the same vehicle.
+// Service is optional and determines on which days the event takes place. If it is nil, then the service
+// of the trip is used. If there is no service at all, the event takes place every day.
 type Event struct {
 	Departure  Time
 	Line       *Line
+	Trip       *Trip
+	Service    *Service
 	NextStop   *Stop
 	TravelTime time.Duration
 }
@@ -177,29 +368,96 @@ type Connection struct {
 	Legs    []Leg
 }
 
-func createConnection(path []*vertex) *Connection {
+func createConnection(path []*label) *Connection {
 	if len(path) < 2 {
 		return nil
 	}
 	legs := make([]Leg, 0, 0)
-	firstStop := path[0]
-	currentLine := path[1].currentLine
-	remaining := path[1:]
-	for i, v := range remaining {
-		if v.currentLine != currentLine {
-			legs = append(legs, Leg{Line: currentLine, FirstStop: firstStop.data, LastStop: remaining[i-1].data})
-			currentLine = v.currentLine
-			firstStop = remaining[i-1]
+	first := 0
+	for i := 2; i < len(path); i++ {
+		if !sameLeg(path[i], path[first+1]) {
+			legs = append(legs, createLeg(path[first:i]))
+			first = i - 1
 		}
 	}
-	legs = append(legs, Leg{Line: currentLine, FirstStop: firstStop.data, LastStop: path[len(path)-1].data})
+	legs = append(legs, createLeg(path[first:]))
 	return &Connection{Legs: legs, Arrival: path[len(path)-1].weight}
 }
 
-// Leg is a part of a journey during which there is no change of lines. A leg
-// has the first stop, a last stop and a line.
+func sameLeg(v1 *label, v2 *label) bool {
+	if v1.event == nil || v2.event == nil {
+		return v1.event == v2.event
+	}
+	return sameVehicle(v1.event, v2.event)
+}
+
+// createLeg creates a leg from the given path. The first label of the path
+// is the stop where the leg begins, all other labels are reached with the same vehicle
+// or all of them are reached by walking.
+func createLeg(path []*label) Leg {
+	firstStop := path[0]
+	lastStop := path[len(path)-1]
+	stops := make([]StopVisit, 0, len(path))
+	for i, v := range path {
+		visit := StopVisit{Stop: v.vertex.data}
+		if i > 0 {
+			visit.Arrival = v.weight
+		}
+		if i < len(path)-1 {
+			visit.Departure = path[i+1].departure
+		}
+		stops = append(stops, visit)
+	}
+	leg := Leg{
+		Kind:      WalkingLeg,
+		Stops:     stops,
+		FirstStop: firstStop.vertex.data,
+		LastStop:  lastStop.vertex.data,
+		Departure: path[1].departure,
+		Arrival:   lastStop.weight,
+		Wait:      path[1].departure.Sub(firstStop.weight),
+	}
+	if event := path[1].event; event != nil {
+		leg.Kind = TransitLeg
+		leg.Line = event.Line
+		leg.Trip = event.Trip
+	}
+	return leg
+}
+
+// LegKind describes how the passenger travels during a leg.
+type LegKind int
+
+const (
+	// TransitLeg is a leg in a public transport vehicle.
+	TransitLeg LegKind = iota
+	// WalkingLeg is a leg on foot using one or more footpaths.
+	WalkingLeg
+)
+
+// Leg is a part of a journey during which there is no change of vehicles. A leg
+// has the first stop, a last stop and a line. If the events of the leg belong to a trip, then
+// the trip is referenced, too. Walking legs have the kind WalkingLeg and neither a line nor a trip. Furthermore, it contains the departure
+// time at the first stop, the arrival time at the last stop, and the time the passenger
+// has to wait at the first stop before the vehicle departs. Stops contains all stops
+// of the leg in the order they are visited, including the first and the last stop.
 type Leg struct {
+	Kind      LegKind
 	Line      *Line
+	Trip      *Trip
 	FirstStop *Stop
 	LastStop  *Stop
+	Departure time.Time
+	Arrival   time.Time
+	Wait      time.Duration
+	Stops     []StopVisit
+}
+
+// StopVisit describes the stay at a stop during a leg. Because the passenger
+// boards the vehicle at the first stop of a leg, the Arrival of the first visit is the zero time.
+// Accordingly, the Departure of the last visit is the zero time.
+type StopVisit struct {
+	Stop      *Stop
+	Arrival   time.Time
+	Departure time.Time
 }
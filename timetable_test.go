@@ -1,13 +1,34 @@
 package routing
 
 import (
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"sync"
 	"testing"
 	"time"
 )
 
-func TestTimetable_Query(t *testing.T) {
+type testNetwork struct {
+	mainStation    *Stop
+	docksAE        *Stop
+	docksFG        *Stop
+	historicMall   *Stop
+	schusterStreet *Stop
+	marketPlace    *Stop
+	airport        *Stop
+	northAvenue    *Stop
+	chalet         *Stop
+	northEnd       *Stop
+	blueLine       *Line
+	redLine        *Line
+}
+
+func (n *testNetwork) stops() []*Stop {
+	return []*Stop{n.mainStation, n.docksAE, n.docksFG, n.historicMall, n.schusterStreet, n.marketPlace, n.airport, n.northAvenue, n.chalet, n.northEnd}
+}
+
+func createTestNetwork() *testNetwork {
 	mainStation := NewStop("MS", "Main Station")
 	docksAE := NewStop("DAE", "Docks A–E")
 	docksFG := NewStop("DFG", "Docks F and G")
@@ -46,26 +67,72 @@ func TestTimetable_Query(t *testing.T) {
 		}
 	}
 
-	timetable := NewTimetable([]*Stop{mainStation, docksAE, docksFG, historicMall, schusterStreet, marketPlace, airport, northAvenue, chalet, northEnd})
+	return &testNetwork{
+		mainStation:    mainStation,
+		docksAE:        docksAE,
+		docksFG:        docksFG,
+		historicMall:   historicMall,
+		schusterStreet: schusterStreet,
+		marketPlace:    marketPlace,
+		airport:        airport,
+		northAvenue:    northAvenue,
+		chalet:         chalet,
+		northEnd:       northEnd,
+		blueLine:       blueLine,
+		redLine:        redLine,
+	}
+}
+
+func TestTimetable_Query(t *testing.T) {
+	network := createTestNetwork()
+	mainStation := network.mainStation
+	schusterStreet := network.schusterStreet
+	northAvenue := network.northAvenue
+	chalet := network.chalet
+	northEnd := network.northEnd
+	blueLine := network.blueLine
+	redLine := network.redLine
+
+	timetable := NewTimetable(network.stops())
 
 	t.Run("single line", func(j *testing.T) {
-		connection := timetable.Query(northAvenue, schusterStreet, date("14:34"))
+		connection, err := timetable.Query(northAvenue, schusterStreet, date("14:34"))
+		require.NoError(t, err)
 		assert.Equal(t, 1, len(connection.Legs), "number of legs in the connection")
 		assert.Equal(t, northAvenue, connection.Legs[0].FirstStop, "first stop wrong")
 		assert.Equal(t, schusterStreet, connection.Legs[0].LastStop, "last stop wrong")
 		assert.Equal(t, blueLine, connection.Legs[0].Line, "line is wrong")
+		assert.Equal(t, date("14:47"), connection.Legs[0].Departure, "departure is wrong")
+		assert.Equal(t, date("14:51"), connection.Legs[0].Arrival, "arrival is wrong")
+		assert.Equal(t, 13*time.Minute, connection.Legs[0].Wait, "wait time is wrong")
+		expectedStops := []StopVisit{
+			{Stop: northAvenue, Departure: date("14:47")},
+			{Stop: network.historicMall, Arrival: date("14:50"), Departure: date("14:50")},
+			{Stop: schusterStreet, Arrival: date("14:51")},
+		}
+		assert.Equal(t, expectedStops, connection.Legs[0].Stops, "stops are wrong")
 		assert.Equal(t, date("14:51"), connection.Arrival, "time is wrong")
 	})
+	t.Run("single line(next day)", func(t *testing.T) {
+		connection, err := timetable.Query(schusterStreet, chalet, date("21:23"))
+		require.NoError(t, err)
+		assert.Equal(t, date("08:11").AddDate(0, 0, 1), connection.Legs[0].Departure, "departure is wrong")
+		assert.Equal(t, date("08:13").AddDate(0, 0, 1), connection.Arrival, "time is wrong")
+	})
 	t.Run("single line(to late)", func(t *testing.T) {
-		connection := timetable.Query(schusterStreet, chalet, date("21:23"))
-		assert.Nil(t, connection, "there is no connection any more")
+		short := NewTimetable(network.stops(), WithSearchHorizon(8*time.Hour))
+		connection, err := short.Query(schusterStreet, chalet, date("21:23"))
+		assert.Equal(t, ErrNoConnection, err, "there is no connection within the horizon")
+		assert.Nil(t, connection, "there is no connection within the horizon")
 	})
 	t.Run("single line(no connection)", func(t *testing.T) {
-		connection := timetable.Query(mainStation, northEnd, date("10:00"))
+		connection, err := timetable.Query(mainStation, northEnd, date("10:00"))
+		assert.Equal(t, ErrNoConnection, err, "there is no connection to the target")
 		assert.Nil(t, connection, "there is no connection to the target")
 	})
 	t.Run("single line", func(j *testing.T) {
-		connection := timetable.Query(mainStation, northAvenue, date("8:04"))
+		connection, err := timetable.Query(mainStation, northAvenue, date("8:04"))
+		require.NoError(t, err)
 		assert.Equal(t, 1, len(connection.Legs), "number of legs in the connection")
 		assert.Equal(t, mainStation, connection.Legs[0].FirstStop, "first stop wrong")
 		assert.Equal(t, northAvenue, connection.Legs[0].LastStop, "last stop wrong")
@@ -73,7 +140,8 @@ func TestTimetable_Query(t *testing.T) {
 		assert.Equal(t, date("8:07"), connection.Arrival, "time is wrong")
 	})
 	t.Run("blue line/red line", func(j *testing.T) {
-		connection := timetable.Query(northEnd, chalet, date("9:30"))
+		connection, err := timetable.Query(northEnd, chalet, date("9:30"))
+		require.NoError(t, err)
 		assert.Equal(t, 2, len(connection.Legs), "number of legs in the connection")
 		assert.Equal(t, northEnd, connection.Legs[0].FirstStop, "first stop wrong")
 		assert.Equal(t, northAvenue, connection.Legs[0].LastStop, "last stop wrong")
@@ -84,7 +152,8 @@ func TestTimetable_Query(t *testing.T) {
 		assert.Equal(t, date("10:13"), connection.Arrival, "time is wrong")
 	})
 	t.Run("blue line/red line(switch time)", func(j *testing.T) {
-		connection := timetable.Query(northEnd, chalet, date("10:25"))
+		connection, err := timetable.Query(northEnd, chalet, date("10:25"))
+		require.NoError(t, err)
 		assert.Equal(t, 2, len(connection.Legs), "number of legs in the connection")
 		assert.Equal(t, northEnd, connection.Legs[0].FirstStop, "first stop wrong")
 		assert.Equal(t, northAvenue, connection.Legs[0].LastStop, "last stop wrong")
@@ -92,18 +161,267 @@ func TestTimetable_Query(t *testing.T) {
 		assert.Equal(t, northAvenue, connection.Legs[1].FirstStop, "first stop wrong")
 		assert.Equal(t, chalet, connection.Legs[1].LastStop, "last stop wrong")
 		assert.Equal(t, blueLine, connection.Legs[1].Line, "line is wrong")
+		assert.Equal(t, date("10:25"), connection.Legs[0].Departure, "departure is wrong")
+		assert.Equal(t, date("10:27"), connection.Legs[0].Arrival, "arrival is wrong")
+		assert.Equal(t, 0*time.Minute, connection.Legs[0].Wait, "wait time is wrong")
+		assert.Equal(t, date("10:47"), connection.Legs[1].Departure, "departure is wrong")
+		assert.Equal(t, date("10:53"), connection.Legs[1].Arrival, "arrival is wrong")
+		assert.Equal(t, 20*time.Minute, connection.Legs[1].Wait, "wait time is wrong")
 		assert.Equal(t, date("10:53"), connection.Arrival, "time is wrong")
 	})
 
 	t.Run("start station not found", func(*testing.T) {
-		assert.PanicsWithValue(t, "source \"Palace\" not found in the timetable", func() {
-			timetable.Query(&Stop{Id: "Palace"}, chalet, time.Now())
-		})
+		connection, err := timetable.Query(&Stop{Id: "Palace"}, chalet, time.Now())
+		assert.True(t, errors.Is(err, ErrUnknownStop), "error should be ErrUnknownStop")
+		assert.EqualError(t, err, "source \"Palace\": stop not found in the timetable", "error message is wrong")
+		assert.Nil(t, connection, "no connection should be returned")
 	})
 	t.Run("target station not found", func(*testing.T) {
-		assert.PanicsWithValue(t, "target \"Palace\" not found in the timetable", func() {
-			timetable.Query(chalet, &Stop{Id: "Palace"}, time.Now())
-		})
+		connection, err := timetable.Query(chalet, &Stop{Id: "Palace"}, time.Now())
+		assert.True(t, errors.Is(err, ErrUnknownStop), "error should be ErrUnknownStop")
+		assert.EqualError(t, err, "target \"Palace\": stop not found in the timetable", "error message is wrong")
+		assert.Nil(t, connection, "no connection should be returned")
+	})
+	t.Run("source is nil", func(*testing.T) {
+		_, err := timetable.Query(nil, chalet, time.Now())
+		assert.True(t, errors.Is(err, ErrUnknownStop), "error should be ErrUnknownStop")
+	})
+	t.Run("invalid departure", func(*testing.T) {
+		palace := NewStop("PA", "Palace")
+		palace.Events = []Event{{Departure: "8.15", Line: network.blueLine, NextStop: chalet, TravelTime: time.Minute}}
+		broken := NewTimetable(append(network.stops(), palace))
+		connection, err := broken.Query(palace, chalet, date("8:00"))
+		assert.True(t, errors.Is(err, ErrInvalidTime), "error should be ErrInvalidTime")
+		assert.EqualError(t, err, "departure at stop \"PA\": the string \"8.15\" is invalid: time does not match the required format", "error message is wrong")
+		assert.Nil(t, connection, "no connection should be returned")
+	})
+}
+
+func TestTimetable_QueryConcurrent(t *testing.T) {
+	network := createTestNetwork()
+	timetable := NewTimetable(network.stops(), WithFootpaths(Footpath{From: network.docksAE, To: network.docksFG, Duration: 4 * time.Minute}))
+	type query struct {
+		source *Stop
+		target *Stop
+		start  time.Time
+	}
+	queries := []query{
+		{source: network.northAvenue, target: network.schusterStreet, start: date("14:34")},
+		{source: network.northEnd, target: network.chalet, start: date("9:30")},
+		{source: network.northEnd, target: network.chalet, start: date("10:25")},
+		{source: network.mainStation, target: network.docksFG, start: date("10:00")},
+		{source: network.northEnd, target: network.airport, start: date("12:17")},
+	}
+	expected := make([]*Connection, len(queries))
+	for i, q := range queries {
+		connection, err := timetable.Query(q.source, q.target, q.start)
+		require.NoError(t, err)
+		expected[i] = connection
+	}
+
+	const goroutines = 16
+	const rounds = 20
+	results := make([][]*Connection, goroutines)
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for r := 0; r < rounds; r++ {
+				i := (g + r) % len(queries)
+				connection, err := timetable.Query(queries[i].source, queries[i].target, queries[i].start)
+				if err != nil || connection.Arrival != expected[i].Arrival {
+					results[g] = append(results[g], connection)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	for g, wrong := range results {
+		assert.Empty(t, wrong, "goroutine %d got wrong results", g)
+	}
+}
+
+func TestTimetable_QueryTransferPolicy(t *testing.T) {
+	network := createTestNetwork()
+	query := func(policy *TransferPolicy, start string) *Connection {
+		timetable := NewTimetable(network.stops(), WithTransferPolicy(policy))
+		connection, err := timetable.Query(network.northEnd, network.chalet, date(start))
+		require.NoError(t, err)
+		return connection
+	}
+
+	t.Run("default", func(t *testing.T) {
+		timetable := NewTimetable(network.stops())
+		connection, err := timetable.Query(network.northEnd, network.chalet, date("10:25"))
+		require.NoError(t, err)
+		assert.Equal(t, date("10:53"), connection.Arrival, "time is wrong")
+	})
+	t.Run("global default", func(t *testing.T) {
+		connection := query(NewTransferPolicy(0), "10:25")
+		assert.Equal(t, date("10:33"), connection.Arrival, "time is wrong")
+	})
+	t.Run("stop override", func(t *testing.T) {
+		policy := NewTransferPolicy(DefaultTransferTime)
+		policy.SetStopTransferTime(network.northAvenue, 8*time.Minute)
+		connection := query(policy, "10:20")
+		assert.Equal(t, date("10:53"), connection.Arrival, "time is wrong")
+
+		policy.SetStopTransferTime(network.northAvenue, 0)
+		connection = query(policy, "10:25")
+		assert.Equal(t, date("10:33"), connection.Arrival, "time is wrong")
+	})
+	t.Run("line override", func(t *testing.T) {
+		policy := NewTransferPolicy(DefaultTransferTime)
+		policy.SetStopTransferTime(network.northAvenue, 8*time.Minute)
+		policy.SetLineTransferTime(network.redLine, network.blueLine, 0)
+		connection := query(policy, "10:25")
+		require.Equal(t, 2, len(connection.Legs), "number of legs in the connection")
+		assert.Equal(t, date("10:33"), connection.Arrival, "time is wrong")
+	})
+	t.Run("stay seated", func(t *testing.T) {
+		policy := NewTransferPolicy(DefaultTransferTime)
+		policy.SetLineTransferTime(network.redLine, network.blueLine, 10*time.Minute)
+		policy.SetStaySeated(network.redLine, network.blueLine)
+		connection := query(policy, "10:25")
+		require.Equal(t, 2, len(connection.Legs), "number of legs in the connection")
+		assert.Equal(t, network.redLine, connection.Legs[0].Line, "line is wrong")
+		assert.Equal(t, network.blueLine, connection.Legs[1].Line, "line is wrong")
+		assert.Equal(t, date("10:33"), connection.Arrival, "time is wrong")
+	})
+}
+
+func TestTimetable_QueryArriveBy(t *testing.T) {
+	network := createTestNetwork()
+	footpath := Footpath{From: network.docksAE, To: network.docksFG, Duration: 4 * time.Minute}
+	timetable := NewTimetable(network.stops(), WithFootpaths(footpath))
+
+	t.Run("with change", func(t *testing.T) {
+		connection, err := timetable.QueryArriveBy(network.northEnd, network.chalet, date("10:55"))
+		require.NoError(t, err)
+		require.Equal(t, 2, len(connection.Legs), "number of legs in the connection")
+		assert.Equal(t, network.redLine, connection.Legs[0].Line, "line of first leg is wrong")
+		assert.Equal(t, date("10:40"), connection.Legs[0].Departure, "departure is wrong")
+		assert.Equal(t, time.Duration(0), connection.Legs[0].Wait, "there is no wait at the beginning")
+		assert.Equal(t, network.blueLine, connection.Legs[1].Line, "line of second leg is wrong")
+		assert.Equal(t, date("10:47"), connection.Legs[1].Departure, "departure is wrong")
+		assert.Equal(t, 5*time.Minute, connection.Legs[1].Wait, "wait time is wrong")
+		assert.Equal(t, date("10:53"), connection.Arrival, "time is wrong")
+	})
+	t.Run("with footpath", func(t *testing.T) {
+		connection, err := timetable.QueryArriveBy(network.northAvenue, network.docksFG, date("12:00"))
+		require.NoError(t, err)
+		require.Equal(t, 2, len(connection.Legs), "number of legs in the connection")
+		assert.Equal(t, date("11:47"), connection.Legs[0].Departure, "departure is wrong")
+		assert.Equal(t, WalkingLeg, connection.Legs[1].Kind, "the footpath should be used")
+		assert.Equal(t, date("11:56"), connection.Legs[1].Departure, "walking should start as late as possible")
+		assert.Equal(t, date("12:00"), connection.Arrival, "time is wrong")
+	})
+	t.Run("previous day", func(t *testing.T) {
+		connection, err := timetable.QueryArriveBy(network.northEnd, network.airport, date("09:00"))
+		require.NoError(t, err)
+		assert.Equal(t, date("19:55").AddDate(0, 0, -1), connection.Legs[0].Departure, "departure is wrong")
+		assert.Equal(t, date("20:07").AddDate(0, 0, -1), connection.Arrival, "time is wrong")
+
+		short := NewTimetable(network.stops(), WithSearchHorizon(8*time.Hour))
+		connection, err = short.QueryArriveBy(network.northEnd, network.airport, date("09:00"))
+		assert.Equal(t, ErrNoConnection, err, "there is no connection within the horizon")
+		assert.Nil(t, connection, "there is no connection within the horizon")
+	})
+	t.Run("latest departure", func(t *testing.T) {
+		for _, source := range network.stops() {
+			for _, target := range network.stops() {
+				for _, deadline := range []string{"8:30", "10:53", "12:17", "15:02", "19:59"} {
+					connection, err := timetable.QueryArriveBy(source, target, date(deadline))
+					if err == ErrNoConnection || source == target {
+						continue
+					}
+					require.NoError(t, err)
+					departure := connection.Legs[0].Departure
+					assert.False(t, connection.Arrival.After(date(deadline)), "%s -> %s arrives after %s", source.Id, target.Id, deadline)
+					forward, err := timetable.Query(source, target, departure)
+					require.NoError(t, err)
+					assert.False(t, forward.Arrival.After(date(deadline)), "%s -> %s: departure %v does not reach the target", source.Id, target.Id, departure)
+					later, err := timetable.Query(source, target, departure.Add(time.Minute))
+					if err == nil {
+						assert.True(t, later.Arrival.After(date(deadline)), "%s -> %s by %s: departure %v is not the latest", source.Id, target.Id, deadline, departure)
+					}
+				}
+			}
+		}
+	})
+	t.Run("grid network", func(t *testing.T) {
+		grid := createGridNetwork(5, 10)
+		timetable := NewTimetable(flattenGrid(grid))
+		connection, err := timetable.QueryArriveBy(grid[0][1], grid[3][0], date("8:30"))
+		require.NoError(t, err)
+		// C1S to 3-1 and R3W to 3-0 arrive at 8:28
+		assert.Equal(t, date("8:10"), connection.Legs[0].Departure, "departure is wrong")
+		assert.Equal(t, date("8:28"), connection.Arrival, "time is wrong")
+
+		raptor := NewRaptor(&timetable)
+		for _, source := range flattenGrid(grid) {
+			for _, target := range flattenGrid(grid) {
+				for _, deadline := range []string{"8:30", "12:17"} {
+					connection, err := timetable.QueryArriveBy(source, target, date(deadline))
+					if err == ErrNoConnection || source == target {
+						continue
+					}
+					require.NoError(t, err)
+					departure := connection.Legs[0].Departure
+					assert.False(t, connection.Arrival.After(date(deadline)), "%s -> %s arrives after %s", source.Id, target.Id, deadline)
+					forward, err := raptor.Query(source, target, departure)
+					require.NoError(t, err)
+					assert.False(t, forward.Arrival.After(date(deadline)), "%s -> %s: departure %v does not reach the target", source.Id, target.Id, departure)
+					later, err := raptor.Query(source, target, departure.Add(time.Minute))
+					if err == nil {
+						assert.True(t, later.Arrival.After(date(deadline)), "%s -> %s by %s: departure %v is not the latest", source.Id, target.Id, deadline, departure)
+					}
+				}
+			}
+		}
+	})
+	t.Run("target not found", func(t *testing.T) {
+		connection, err := timetable.QueryArriveBy(network.northEnd, NewStop("XY", "Unknown"), date("10:00"))
+		assert.True(t, errors.Is(err, ErrUnknownStop), "error should be ErrUnknownStop")
+		assert.Nil(t, connection, "no connection should be returned")
+	})
+}
+
+func TestTimetable_QueryOvernight(t *testing.T) {
+	zoo := NewStop("ZO", "Zoo")
+	mall := NewStop("MA", "Mall")
+	court := NewStop("CO", "Court")
+	nightLine := &Line{Name: "N1", Id: "N1"}
+	weekdays := NewService("WD", time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday)
+	_, err := NewTrip("N1-2350", nightLine, []StopTime{{Stop: zoo, Departure: "23:55"}, {Stop: mall, Arrival: "24:20", Departure: "24:20"}, {Stop: court, Arrival: "25:10"}})
+	require.NoError(t, err)
+	morning, err := NewTrip("N1-0500", nightLine, []StopTime{{Stop: mall, Departure: "5:00"}, {Stop: court, Arrival: "5:30"}})
+	require.NoError(t, err)
+	morning.Service = weekdays
+	timetable := NewTimetable([]*Stop{zoo, mall, court})
+
+	t.Run("across midnight", func(t *testing.T) {
+		connection, err := timetable.Query(zoo, court, date("23:50"))
+		require.NoError(t, err)
+		assert.Equal(t, date("23:55"), connection.Legs[0].Departure, "departure is wrong")
+		assert.Equal(t, date("01:10").AddDate(0, 0, 1), connection.Arrival, "time is wrong")
+	})
+	t.Run("previous day", func(t *testing.T) {
+		connection, err := timetable.Query(mall, court, date("00:10"))
+		require.NoError(t, err)
+		assert.Equal(t, date("00:20"), connection.Legs[0].Departure, "the trip of the previous day should be used")
+		assert.Equal(t, date("01:10"), connection.Arrival, "time is wrong")
+	})
+	t.Run("next day", func(t *testing.T) {
+		connection, err := timetable.Query(mall, court, date("00:30"))
+		require.NoError(t, err)
+		assert.Equal(t, date("05:00"), connection.Legs[0].Departure, "the morning trip should be used")
+
+		saturday := date("00:30").AddDate(0, 0, 2)
+		connection, err = timetable.Query(mall, court, saturday)
+		require.NoError(t, err)
+		assert.Equal(t, date("00:20").AddDate(0, 0, 3), connection.Legs[0].Departure, "the night trip of Saturday should be used")
 	})
 }
 
@@ -143,36 +461,88 @@ func TestEventGroup_WeightFunction(t *testing.T) {
 	e6 := Event{Line: harbourExpress, Departure: "14:35", TravelTime: 12 * time.Minute}
 
 	group := eventGroup([]Event{e1, e2, e3, e4, e6})
+	stop := &Stop{Name: "Central Station", Id: "CS"}
+	policy := NewTransferPolicy(DefaultTransferTime)
+	deadline := date("00:00").AddDate(0, 0, 1)
 	t.Run("without change", func(t *testing.T) {
 		now := date("14:34")
-		function := group.weightFunction(now)
-		duration, line, b := function(now, southBound)
-		assert.Equal(t, 10*time.Minute, duration, "duration is wrong")
-		assert.Equal(t, southBound, line, "line after event is wrong")
+		departures, err := group.departures()
+		require.NoError(t, err)
+		function := unconstrained(constrainedWeightFunction(stop, departures, policy))
+		hop, b := function(deadline, now, &Event{Line: southBound})
+		assert.Equal(t, date("14:44"), hop.arrival, "arrival is wrong")
+		assert.Equal(t, date("14:39"), hop.departure, "departure is wrong")
+		assert.Equal(t, southBound, hop.event.Line, "line after event is wrong")
 		assert.True(t, b, "connection should be found")
 	})
 	t.Run("without start line", func(t *testing.T) {
 		now := date("14:34")
-		function := group.weightFunction(now)
-		duration, line, b := function(now, nil)
-		assert.Equal(t, 9*time.Minute, duration, "duration is wrong")
-		assert.Equal(t, harbour, line, "line after event is wrong")
+		departures, err := group.departures()
+		require.NoError(t, err)
+		function := unconstrained(constrainedWeightFunction(stop, departures, policy))
+		hop, b := function(deadline, now, nil)
+		assert.Equal(t, date("14:43"), hop.arrival, "arrival is wrong")
+		assert.Equal(t, date("14:35"), hop.departure, "departure is wrong")
+		assert.Equal(t, harbour, hop.event.Line, "line after event is wrong")
 		assert.True(t, b, "connection should be found")
 	})
 	t.Run("with change", func(t *testing.T) {
 		now := date("14:30")
-		function := group.weightFunction(now)
-		duration, line, b := function(now, harbour)
-		assert.Equal(t, 13*time.Minute, duration, "duration is wrong")
-		assert.Equal(t, harbour, line, "line after event is wrong")
+		departures, err := group.departures()
+		require.NoError(t, err)
+		function := unconstrained(constrainedWeightFunction(stop, departures, policy))
+		hop, b := function(deadline, now, &Event{Line: harbour})
+		assert.Equal(t, date("14:43"), hop.arrival, "arrival is wrong")
+		assert.Equal(t, harbour, hop.event.Line, "line after event is wrong")
+		assert.True(t, b, "connection should be found")
+	})
+	t.Run("with stop transfer time", func(t *testing.T) {
+		now := date("14:30")
+		stopPolicy := NewTransferPolicy(DefaultTransferTime)
+		stopPolicy.SetStopTransferTime(stop, 0)
+		departures, err := group.departures()
+		require.NoError(t, err)
+		function := unconstrained(constrainedWeightFunction(stop, departures, stopPolicy))
+		hop, b := function(deadline, now, &Event{Line: harbour})
+		assert.Equal(t, date("14:35"), hop.arrival, "arrival is wrong")
+		assert.Equal(t, southBound, hop.event.Line, "line after event is wrong")
 		assert.True(t, b, "connection should be found")
 	})
 	t.Run("no departure found", func(t *testing.T) {
 		now := date("16:00")
-		function := group.weightFunction(now)
-		_, _, b := function(now, harbourExpress)
+		departures, err := group.departures()
+		require.NoError(t, err)
+		function := unconstrained(constrainedWeightFunction(stop, departures, policy))
+		_, b := function(deadline, now, &Event{Line: harbourExpress})
 		assert.False(t, b, "no connection should be found any more")
 	})
+	t.Run("next day", func(t *testing.T) {
+		now := date("16:00")
+		departures, err := group.departures()
+		require.NoError(t, err)
+		function := unconstrained(constrainedWeightFunction(stop, departures, policy))
+		hop, b := function(deadline.AddDate(0, 0, 1), now, &Event{Line: harbourExpress})
+		assert.Equal(t, date("14:30").AddDate(0, 0, 1), hop.departure, "departure is wrong")
+		assert.Equal(t, date("14:35").AddDate(0, 0, 1), hop.arrival, "arrival is wrong")
+		assert.True(t, b, "connection should be found")
+	})
+	t.Run("previous day", func(t *testing.T) {
+		night := eventGroup([]Event{
+			{Line: southBound, Departure: "5:00", TravelTime: 5 * time.Minute},
+			{Line: southBound, Departure: "25:10", TravelTime: 5 * time.Minute},
+		})
+		departures, err := night.departures()
+		require.NoError(t, err)
+		function := unconstrained(constrainedWeightFunction(stop, departures, policy))
+		hop, b := function(deadline, date("01:00"), nil)
+		assert.Equal(t, date("01:10"), hop.departure, "departure of the previous day should be used")
+		assert.Equal(t, date("01:15"), hop.arrival, "arrival is wrong")
+		assert.True(t, b, "connection should be found")
+
+		hop, b = function(date("23:50").Add(DefaultSearchHorizon), date("23:50"), nil)
+		assert.Equal(t, date("01:10").AddDate(0, 0, 1), hop.departure, "departure after midnight should be used")
+		assert.True(t, b, "connection should be found")
+	})
 }
 
 func Test_createConnection(t *testing.T) {
@@ -185,12 +555,12 @@ func Test_createConnection(t *testing.T) {
 	mainStreet := &Stop{Name: "Main Street", Id: "MS"}
 	centralStation := &Stop{Name: "Central Station", Id: "CS"}
 
-	v1 := &vertex{data: zoo}
-	v2 := &vertex{data: mall, currentLine: southBound}
-	v3 := &vertex{data: court, currentLine: southBound}
-	v4 := &vertex{data: mainStreet, currentLine: southBound}
-	v5 := &vertex{data: centralStation, currentLine: harbour}
-	path := []*vertex{v1, v2, v3, v4, v5}
+	v1 := &label{vertex: &vertex{data: zoo}, weight: date("9:58")}
+	v2 := &label{vertex: &vertex{data: mall}, event: &Event{Line: southBound}, departure: date("10:00"), weight: date("10:02")}
+	v3 := &label{vertex: &vertex{data: court}, event: &Event{Line: southBound}, departure: date("10:03"), weight: date("10:05")}
+	v4 := &label{vertex: &vertex{data: mainStreet}, event: &Event{Line: southBound}, departure: date("10:05"), weight: date("10:08")}
+	v5 := &label{vertex: &vertex{data: centralStation}, event: &Event{Line: harbour}, departure: date("10:15"), weight: date("10:20")}
+	path := []*label{v1, v2, v3, v4, v5}
 
 	t.Run("test big", func(t *testing.T) {
 		got := createConnection(path)
@@ -201,6 +571,25 @@ func Test_createConnection(t *testing.T) {
 		assert.Equal(t, mainStreet, got.Legs[0].LastStop, "last stop of leg 0 not correct")
 		assert.Equal(t, mainStreet, got.Legs[1].FirstStop, "first stop of leg 1 not correct")
 		assert.Equal(t, centralStation, got.Legs[1].LastStop, "last stop of leg 1 not correct")
+		assert.Equal(t, date("10:00"), got.Legs[0].Departure, "departure of leg 0 not correct")
+		assert.Equal(t, date("10:08"), got.Legs[0].Arrival, "arrival of leg 0 not correct")
+		assert.Equal(t, 2*time.Minute, got.Legs[0].Wait, "wait time of leg 0 not correct")
+		assert.Equal(t, date("10:15"), got.Legs[1].Departure, "departure of leg 1 not correct")
+		assert.Equal(t, date("10:20"), got.Legs[1].Arrival, "arrival of leg 1 not correct")
+		assert.Equal(t, 7*time.Minute, got.Legs[1].Wait, "wait time of leg 1 not correct")
+		assert.Equal(t, date("10:20"), got.Arrival, "arrival not correct")
+		expectedStops := []StopVisit{
+			{Stop: zoo, Departure: date("10:00")},
+			{Stop: mall, Arrival: date("10:02"), Departure: date("10:03")},
+			{Stop: court, Arrival: date("10:05"), Departure: date("10:05")},
+			{Stop: mainStreet, Arrival: date("10:08")},
+		}
+		assert.Equal(t, expectedStops, got.Legs[0].Stops, "stops of leg 0 not correct")
+		expectedStops = []StopVisit{
+			{Stop: mainStreet, Departure: date("10:15")},
+			{Stop: centralStation, Arrival: date("10:20")},
+		}
+		assert.Equal(t, expectedStops, got.Legs[1].Stops, "stops of leg 1 not correct")
 	})
 	t.Run("small", func(t *testing.T) {
 		got := createConnection(path[3:])
@@ -213,13 +602,14 @@ func Test_createConnection(t *testing.T) {
 
 func TestTime_interpret(t *testing.T) {
 	t.Run("invalid format", func(t *testing.T) {
-		assert.PanicsWithValue(t, "the string \"a123:23\" does not match the required format", func() {
-			Time("a123:23").interpret(time.Now())
-		})
+		_, err := Time("a123:23").interpret(time.Now())
+		assert.True(t, errors.Is(err, ErrInvalidTime), "error should be ErrInvalidTime")
+		assert.EqualError(t, err, "the string \"a123:23\" is invalid: time does not match the required format", "error message is wrong")
 	})
 	t.Run("very big hours", func(t *testing.T) {
 		now := time.Now()
-		got := Time("123:40").interpret(now)
+		got, err := Time("123:40").interpret(now)
+		require.NoError(t, err)
 		expected := time.Date(now.Year(), now.Month(), now.Day()+5, 3, 40, 0, 0, now.Location())
 		assert.Equal(t, expected.Format(time.RFC3339), got.Format(time.RFC3339), "interpeted time not correct")
 	})
@@ -0,0 +1,128 @@
+package routing
+
+import "time"
+
+// DefaultTransferTime is the minimum time a passenger needs to change from one line to another
+// if nothing else is configured.
+const DefaultTransferTime = 5 * time.Minute
+
+// TransferPolicy determines the minimum time a passenger needs to change from one line to another.
+// The time is determined in the following order:
+//
+// 1. If the passenger stays on the same line, then no transfer time is needed. If the events
+// of both vehicles belong to trips, then the passenger must stay on the same trip instead.
+// 2. If the trips or the lines are marked as "stay seated" (e.g. through-running lines), no transfer time is needed.
+// 3. If there is a transfer time for the pair of lines at the stop, it is used.
+// 4. If there is a transfer time for the pair of lines, it is used.
+// 5. If there is a transfer time for the stop, it is used.
+// 6. Otherwise, the Default transfer time is used.
+//
+// Transfer policies should be created with the NewTransferPolicy function.
+type TransferPolicy struct {
+	Default     time.Duration
+	stops       map[string]time.Duration
+	lines       map[linePair]time.Duration
+	stopLines   map[stopLinePair]time.Duration
+	staySeated  map[linePair]bool
+	seatedTrips map[tripPair]bool
+}
+
+type linePair struct {
+	from *Line
+	to   *Line
+}
+
+type stopLinePair struct {
+	stop string
+	linePair
+}
+
+type tripPair struct {
+	from *Trip
+	to   *Trip
+}
+
+// NewTransferPolicy creates a new transfer policy which uses the given transfer time
+// everywhere unless it is overridden.
+func NewTransferPolicy(defaultTime time.Duration) *TransferPolicy {
+	return &TransferPolicy{
+		Default:     defaultTime,
+		stops:       make(map[string]time.Duration),
+		lines:       make(map[linePair]time.Duration),
+		stopLines:   make(map[stopLinePair]time.Duration),
+		staySeated:  make(map[linePair]bool),
+		seatedTrips: make(map[tripPair]bool),
+	}
+}
+
+// SetStopTransferTime overrides the default transfer time for all transfers at the given stop.
+func (p *TransferPolicy) SetStopTransferTime(stop *Stop, transferTime time.Duration) {
+	p.stops[stop.Id] = transferTime
+}
+
+// SetLineTransferTime overrides the transfer time when changing from the line "from"
+// to the line "to". The override applies to all stops and is not symmetric.
+func (p *TransferPolicy) SetLineTransferTime(from *Line, to *Line, transferTime time.Duration) {
+	p.lines[linePair{from: from, to: to}] = transferTime
+}
+
+// SetStopLineTransferTime overrides the transfer time when changing from the line "from"
+// to the line "to" at the given stop. The override is not symmetric.
+func (p *TransferPolicy) SetStopLineTransferTime(stop *Stop, from *Line, to *Line, transferTime time.Duration) {
+	p.stopLines[stopLinePair{stop: stop.Id, linePair: linePair{from: from, to: to}}] = transferTime
+}
+
+// SetStaySeated marks the line "to" as continuation of the line "from". Passengers
+// can stay in the vehicle when changing from "from" to "to", thus the transfer time
+// is always zero. The setting is not symmetric.
+func (p *TransferPolicy) SetStaySeated(from *Line, to *Line) {
+	p.staySeated[linePair{from: from, to: to}] = true
+}
+
+// SetTripStaySeated marks the trip "to" as continuation of the trip "from", e.g. because
+// the vehicle of "from" continues as "to". Contrary to SetStaySeated, the setting applies only to this
+// pair of trips and not to other trips of their lines. The setting is not symmetric.
+func (p *TransferPolicy) SetTripStaySeated(from *Trip, to *Trip) {
+	p.seatedTrips[tripPair{from: from, to: to}] = true
+}
+
+// TransferTime returns the minimum time a passenger needs at the given stop in order
+// to change from the line "from" to the line "to". If "from" is nil, then the passenger
+// has not used any line yet and the transfer time is zero.
+func (p *TransferPolicy) TransferTime(stop *Stop, from *Line, to *Line) time.Duration {
+	if from == nil || from == to {
+		return 0 * time.Minute
+	}
+	return p.changeTime(stop, from, to)
+}
+
+// eventTransferTime returns the minimum time a passenger needs at the given stop in order to
+// change from the vehicle of the event "from" to the vehicle of the event "to". Changing between
+// different trips of the same line is a transfer, too. If one of the events is nil, then
+// the passenger starts or ends the journey at the stop and the transfer time is zero.
+func (p *TransferPolicy) eventTransferTime(stop *Stop, from *Event, to *Event) time.Duration {
+	if from == nil || to == nil || sameVehicle(from, to) {
+		return 0 * time.Minute
+	}
+	if from.Trip != nil && to.Trip != nil && p.seatedTrips[tripPair{from: from.Trip, to: to.Trip}] {
+		return 0 * time.Minute
+	}
+	return p.changeTime(stop, from.Line, to.Line)
+}
+
+func (p *TransferPolicy) changeTime(stop *Stop, from *Line, to *Line) time.Duration {
+	pair := linePair{from: from, to: to}
+	if p.staySeated[pair] {
+		return 0 * time.Minute
+	}
+	if transferTime, ok := p.stopLines[stopLinePair{stop: stop.Id, linePair: pair}]; ok {
+		return transferTime
+	}
+	if transferTime, ok := p.lines[pair]; ok {
+		return transferTime
+	}
+	if transferTime, ok := p.stops[stop.Id]; ok {
+		return transferTime
+	}
+	return p.Default
+}
@@ -0,0 +1,89 @@
+package routing
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestTransferPolicy_TransferTime(t *testing.T) {
+	southBound := &Line{Name: "1 SouthBound", Id: "1"}
+	harbour := &Line{Name: "2 Harbour", Id: "2"}
+	harbourExpress := &Line{Name: "2a Harbour", Id: "2a"}
+	centralStation := &Stop{Name: "Central Station", Id: "CS"}
+	zoo := &Stop{Name: "Zoo", Id: "ZO"}
+
+	policy := NewTransferPolicy(4 * time.Minute)
+	policy.SetStopTransferTime(centralStation, 8*time.Minute)
+	policy.SetLineTransferTime(southBound, harbour, 2*time.Minute)
+	policy.SetStaySeated(harbour, harbourExpress)
+	policy.SetStopLineTransferTime(zoo, harbour, southBound, 3*time.Minute)
+
+	t.Run("same line", func(t *testing.T) {
+		assert.Equal(t, 0*time.Minute, policy.TransferTime(centralStation, harbour, harbour), "transfer time is wrong")
+	})
+	t.Run("no line yet", func(t *testing.T) {
+		assert.Equal(t, 0*time.Minute, policy.TransferTime(centralStation, nil, harbour), "transfer time is wrong")
+	})
+	t.Run("default", func(t *testing.T) {
+		assert.Equal(t, 4*time.Minute, policy.TransferTime(zoo, harbourExpress, southBound), "transfer time is wrong")
+	})
+	t.Run("stop override", func(t *testing.T) {
+		assert.Equal(t, 8*time.Minute, policy.TransferTime(centralStation, harbour, southBound), "transfer time is wrong")
+	})
+	t.Run("line override", func(t *testing.T) {
+		assert.Equal(t, 2*time.Minute, policy.TransferTime(centralStation, southBound, harbour), "transfer time is wrong")
+		assert.Equal(t, 2*time.Minute, policy.TransferTime(zoo, southBound, harbour), "transfer time is wrong")
+	})
+	t.Run("line override at stop", func(t *testing.T) {
+		assert.Equal(t, 3*time.Minute, policy.TransferTime(zoo, harbour, southBound), "transfer time is wrong")
+		assert.Equal(t, 4*time.Minute, policy.TransferTime(zoo, southBound, harbourExpress), "transfer time is wrong")
+		assert.Equal(t, 8*time.Minute, policy.TransferTime(centralStation, harbour, southBound), "transfer time is wrong")
+	})
+	t.Run("stay seated", func(t *testing.T) {
+		assert.Equal(t, 0*time.Minute, policy.TransferTime(centralStation, harbour, harbourExpress), "transfer time is wrong")
+		assert.Equal(t, 8*time.Minute, policy.TransferTime(centralStation, harbourExpress, harbour), "transfer time is wrong")
+	})
+}
+
+func TestTransferPolicy_eventTransferTime(t *testing.T) {
+	harbour := &Line{Name: "2 Harbour", Id: "2"}
+	harbourExpress := &Line{Name: "2a Harbour", Id: "2a"}
+	centralStation := &Stop{Name: "Central Station", Id: "CS"}
+	trip1 := &Trip{Id: "2-1", Line: harbour}
+	trip2 := &Trip{Id: "2-2", Line: harbour}
+
+	policy := NewTransferPolicy(4 * time.Minute)
+
+	t.Run("same trip", func(t *testing.T) {
+		got := policy.eventTransferTime(centralStation, &Event{Line: harbour, Trip: trip1}, &Event{Line: harbour, Trip: trip1})
+		assert.Equal(t, 0*time.Minute, got, "transfer time is wrong")
+	})
+	t.Run("different trips of same line", func(t *testing.T) {
+		got := policy.eventTransferTime(centralStation, &Event{Line: harbour, Trip: trip1}, &Event{Line: harbour, Trip: trip2})
+		assert.Equal(t, 4*time.Minute, got, "transfer time is wrong")
+	})
+	t.Run("same line without trips", func(t *testing.T) {
+		got := policy.eventTransferTime(centralStation, &Event{Line: harbour}, &Event{Line: harbour, Trip: trip2})
+		assert.Equal(t, 0*time.Minute, got, "transfer time is wrong")
+	})
+	t.Run("different lines", func(t *testing.T) {
+		got := policy.eventTransferTime(centralStation, &Event{Line: harbour}, &Event{Line: harbourExpress})
+		assert.Equal(t, 4*time.Minute, got, "transfer time is wrong")
+	})
+	t.Run("stay seated trips", func(t *testing.T) {
+		seated := NewTransferPolicy(4 * time.Minute)
+		trip3 := &Trip{Id: "2-3", Line: harbour}
+		seated.SetTripStaySeated(trip1, trip2)
+		got := seated.eventTransferTime(centralStation, &Event{Line: harbour, Trip: trip1}, &Event{Line: harbour, Trip: trip2})
+		assert.Equal(t, 0*time.Minute, got, "transfer time is wrong")
+		got = seated.eventTransferTime(centralStation, &Event{Line: harbour, Trip: trip1}, &Event{Line: harbour, Trip: trip3})
+		assert.Equal(t, 4*time.Minute, got, "transfer time is wrong")
+		got = seated.eventTransferTime(centralStation, &Event{Line: harbour, Trip: trip2}, &Event{Line: harbour, Trip: trip1})
+		assert.Equal(t, 4*time.Minute, got, "transfer time is wrong")
+	})
+	t.Run("source", func(t *testing.T) {
+		got := policy.eventTransferTime(centralStation, nil, &Event{Line: harbourExpress})
+		assert.Equal(t, 0*time.Minute, got, "transfer time is wrong")
+	})
+}
@@ -0,0 +1,201 @@
+package routing
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Trip is a single run of a vehicle of a line, e.g. the bus of line 12 leaving
+// the depot at 08:15. The stop times of a trip are ordered and describe when the vehicle
+// arrives at and departs from its stops. The Service of the trip determines on which days the trip
+// runs, if it is nil, the trip runs every day. Trips should be created with the NewTrip function.
+//
+// The Id of a trip should be unique among all trips.
+type Trip struct {
+	Id        string
+	Line      *Line
+	Service   *Service
+	StopTimes []StopTime
+}
+
+// StopTime describes when the vehicle of a trip arrives at and departs from a stop.
+// The arrival of the first stop time and the departure of the last stop time of a trip are
+// not evaluated.
+type StopTime struct {
+	Stop      *Stop
+	Arrival   Time
+	Departure Time
+}
+
+// NewTrip creates a new trip and appends the departure events of the trip to its stops.
+// Thus, the stops must not be modified afterwards in order to add the trip.
+// The travel time of an event is the difference between the departure at the event's stop and the
+// arrival at the following stop of the trip. All times must match the TimeRegex, otherwise ErrInvalidTime
+// is returned and no events are added to the stops. An error is returned as well if a stop time has no stop.
+func NewTrip(id string, line *Line, stopTimes []StopTime) (*Trip, error) {
+	for i, stopTime := range stopTimes {
+		if stopTime.Stop == nil {
+			return nil, fmt.Errorf("stop time %d of trip \"%s\" has no stop", i, id)
+		}
+	}
+	trip := &Trip{Id: id, Line: line, StopTimes: stopTimes}
+	var reference time.Time
+	events := make([]Event, 0, len(stopTimes))
+	for i := 0; i < len(stopTimes)-1; i++ {
+		current := stopTimes[i]
+		next := stopTimes[i+1]
+		departure, err := current.Departure.interpret(reference)
+		if err != nil {
+			return nil, fmt.Errorf("departure at stop \"%s\" of trip \"%s\": %w", current.Stop.Id, id, err)
+		}
+		arrival, err := next.Arrival.interpret(reference)
+		if err != nil {
+			return nil, fmt.Errorf("arrival at stop \"%s\" of trip \"%s\": %w", next.Stop.Id, id, err)
+		}
+		event := Event{Departure: current.Departure, Line: line, Trip: trip, NextStop: next.Stop, TravelTime: arrival.Sub(departure)}
+		events = append(events, event)
+	}
+	for i, event := range events {
+		stop := stopTimes[i].Stop
+		stop.Events = append(stop.Events, event)
+	}
+	return trip, nil
+}
+
+func sameVehicle(e1 *Event, e2 *Event) bool {
+	if e1.Trip != nil && e2.Trip != nil {
+		return e1.Trip == e2.Trip
+	}
+	return e1.Line == e2.Line
+}
+
+// chainedEvent is an event without trip that is chained to the event of the same vehicle at the next stop.
+type chainedEvent struct {
+	stop        *Stop
+	event       *Event
+	offset      time.Duration
+	arrival     time.Duration
+	next        *chainedEvent
+	predecessor bool
+}
+
+// runs chains the events of the timetable to the runs of the vehicles and returns the first event of
+// every run. Events which reference a trip are chained to the events of the same trip, all other events to the
+// events of the same line and service: an event is continued by the event at its next stop which departs first
+// after the arrival of the vehicle and is not continuing another event yet.
+func (t *Timetable) runs() ([]*chainedEvent, error) {
+	chained := make([]*chainedEvent, 0, 0)
+	type vehicleStop struct {
+		trip    *Trip
+		line    *Line
+		service *Service
+		stop    string
+	}
+	key := func(event *Event, stop *Stop) vehicleStop {
+		if event.Trip != nil {
+			return vehicleStop{trip: event.Trip, stop: stop.Id}
+		}
+		return vehicleStop{line: event.Line, service: event.Service, stop: stop.Id}
+	}
+	groups := make(map[vehicleStop][]*chainedEvent)
+	for _, vertex := range t.graph.vertices {
+		stop := vertex.data
+		for i := range stop.Events {
+			event := &stop.Events[i]
+			if !t.contains(event.NextStop) {
+				continue
+			}
+			offset, err := event.Departure.offset()
+			if err != nil {
+				return nil, fmt.Errorf("departure at stop \"%s\": %w", stop.Id, err)
+			}
+			c := &chainedEvent{stop: stop, event: event, offset: offset, arrival: offset + event.TravelTime}
+			chained = append(chained, c)
+			groups[key(event, stop)] = append(groups[key(event, stop)], c)
+		}
+	}
+	for _, group := range groups {
+		sort.SliceStable(group, func(i, j int) bool {
+			return group[i].offset < group[j].offset
+		})
+	}
+	byArrival := make([]*chainedEvent, len(chained))
+	copy(byArrival, chained)
+	sort.SliceStable(byArrival, func(i, j int) bool {
+		return byArrival[i].arrival < byArrival[j].arrival
+	})
+	for _, c := range byArrival {
+		group := groups[key(c.event, c.event.NextStop)]
+		first := sort.Search(len(group), func(i int) bool {
+			return group[i].offset >= c.arrival
+		})
+		for _, candidate := range group[first:] {
+			if !candidate.predecessor {
+				candidate.predecessor = true
+				c.next = candidate
+				break
+			}
+		}
+	}
+	result := make([]*chainedEvent, 0, 0)
+	for _, c := range chained {
+		if !c.predecessor {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
+
+// trips returns all trips of the timetable. Events which reference a trip are represented
+// by their trip. Events without trip are chained to trips, see runs. Trips only have one service,
+// thus an error is returned if an event of a trip has a service other than the one of its trip.
+func (t *Timetable) trips() ([]*Trip, error) {
+	result := make([]*Trip, 0, 0)
+	seen := make(map[*Trip]bool)
+	for _, vertex := range t.graph.vertices {
+		for _, event := range vertex.data.Events {
+			if event.Trip != nil && event.Service != nil && event.Service != event.Trip.Service {
+				return nil, fmt.Errorf("event at stop \"%s\" of trip \"%s\" has a service other than its trip", vertex.data.Id, event.Trip.Id)
+			}
+			if event.Trip != nil && !seen[event.Trip] {
+				seen[event.Trip] = true
+				result = append(result, event.Trip)
+			}
+		}
+	}
+	runs, err := t.runs()
+	if err != nil {
+		return nil, err
+	}
+	counter := make(map[*Line]int)
+	for _, c := range runs {
+		if c.event.Trip != nil {
+			continue
+		}
+		counter[c.event.Line]++
+		trip := &Trip{Line: c.event.Line, Service: c.event.Service}
+		if c.event.Line != nil {
+			trip.Id = fmt.Sprintf("%s-%d", c.event.Line.Id, counter[c.event.Line])
+		} else {
+			trip.Id = fmt.Sprintf("%d", counter[c.event.Line])
+		}
+		trip.StopTimes = append(trip.StopTimes, StopTime{Stop: c.stop, Arrival: c.event.Departure, Departure: c.event.Departure})
+		for ; c != nil; c = c.next {
+			arrival := formatOffset(c.arrival)
+			departure := arrival
+			if c.next != nil {
+				departure = c.next.event.Departure
+			}
+			trip.StopTimes = append(trip.StopTimes, StopTime{Stop: c.event.NextStop, Arrival: arrival, Departure: departure})
+		}
+		result = append(result, trip)
+	}
+	return result, nil
+}
+
+// formatOffset converts the duration since midnight to a Time.
+func formatOffset(offset time.Duration) Time {
+	minutes := int(offset / time.Minute)
+	return CreateTime(minutes/60, minutes%60)
+}
@@ -0,0 +1,76 @@
+package routing
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestNewTrip(t *testing.T) {
+	zoo := NewStop("ZO", "Zoo")
+	mall := NewStop("MA", "Mall")
+	court := NewStop("CO", "Court")
+	southBound := &Line{Name: "1 SouthBound", Id: "1"}
+
+	trip, err := NewTrip("1-0815", southBound, []StopTime{
+		{Stop: zoo, Departure: "8:15"},
+		{Stop: mall, Arrival: "8:19", Departure: "8:21"},
+		{Stop: court, Arrival: "8:30"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "1-0815", trip.Id, "id of trip is wrong")
+	assert.Equal(t, southBound, trip.Line, "line of trip is wrong")
+	assert.Equal(t, []Event{{Departure: "8:15", Line: southBound, Trip: trip, NextStop: mall, TravelTime: 4 * time.Minute}}, zoo.Events, "events of zoo are wrong")
+	assert.Equal(t, []Event{{Departure: "8:21", Line: southBound, Trip: trip, NextStop: court, TravelTime: 9 * time.Minute}}, mall.Events, "events of mall are wrong")
+	assert.Equal(t, 0, len(court.Events), "the last stop of a trip has no events")
+
+	t.Run("invalid time", func(t *testing.T) {
+		trip, err := NewTrip("1-0915", southBound, []StopTime{
+			{Stop: zoo, Departure: "9:15"},
+			{Stop: mall, Arrival: "9.19", Departure: "9:21"},
+			{Stop: court, Arrival: "9:30"},
+		})
+		assert.True(t, errors.Is(err, ErrInvalidTime), "error should be ErrInvalidTime")
+		assert.EqualError(t, err, "arrival at stop \"MA\" of trip \"1-0915\": the string \"9.19\" is invalid: time does not match the required format", "error message is wrong")
+		assert.Nil(t, trip, "no trip should be returned")
+		assert.Equal(t, 1, len(zoo.Events), "no events must be added")
+	})
+	t.Run("nil stop", func(t *testing.T) {
+		trip, err := NewTrip("1-1015", southBound, []StopTime{
+			{Stop: zoo, Departure: "10:15"},
+			{Arrival: "10:19", Departure: "10:21"},
+			{Stop: court, Arrival: "10:30"},
+		})
+		assert.EqualError(t, err, "stop time 1 of trip \"1-1015\" has no stop", "error message is wrong")
+		assert.Nil(t, trip, "no trip should be returned")
+		assert.Equal(t, 1, len(zoo.Events), "no events must be added")
+	})
+}
+
+func TestTimetable_QueryTrips(t *testing.T) {
+	zoo := NewStop("ZO", "Zoo")
+	mall := NewStop("MA", "Mall")
+	court := NewStop("CO", "Court")
+	southBound := &Line{Name: "1 SouthBound", Id: "1"}
+
+	// trip 1 ends at the mall, trip 2 leaves the mall at the same minute trip 1 arrives
+	trip1, err := NewTrip("1-1000", southBound, []StopTime{{Stop: zoo, Departure: "10:00"}, {Stop: mall, Arrival: "10:05"}})
+	require.NoError(t, err)
+	_, err = NewTrip("1-1005", southBound, []StopTime{{Stop: mall, Departure: "10:05"}, {Stop: court, Arrival: "10:10"}})
+	require.NoError(t, err)
+	trip3, err := NewTrip("1-1020", southBound, []StopTime{{Stop: mall, Departure: "10:20"}, {Stop: court, Arrival: "10:25"}})
+	require.NoError(t, err)
+	timetable := NewTimetable([]*Stop{zoo, mall, court})
+
+	connection, err := timetable.Query(zoo, court, date("9:55"))
+	require.NoError(t, err)
+	require.Equal(t, 2, len(connection.Legs), "number of legs in the connection")
+	assert.Equal(t, trip1, connection.Legs[0].Trip, "trip of first leg is wrong")
+	assert.Equal(t, mall, connection.Legs[0].LastStop, "last stop of first leg is wrong")
+	assert.Equal(t, trip3, connection.Legs[1].Trip, "trip of second leg is wrong")
+	assert.Equal(t, southBound, connection.Legs[1].Line, "line of second leg is wrong")
+	assert.Equal(t, date("10:25"), connection.Arrival, "changing the trip must take the transfer time")
+}
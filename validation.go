@@ -0,0 +1,174 @@
+package routing
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ProblemKind classifies the problems found when validating a timetable.
+type ProblemKind int
+
+const (
+	// DuplicateStopId means that the Id of a stop is used by another stop, too.
+	DuplicateStopId ProblemKind = iota
+	// DanglingNextStop means that the NextStop of an event is nil or not part of the timetable.
+	DanglingNextStop
+	// InvalidDeparture means that the Departure of an event does not match the TimeRegex.
+	InvalidDeparture
+	// NegativeTravelTime means that the TravelTime of an event is negative.
+	NegativeTravelTime
+	// MissingLine means that the Line of an event is nil.
+	MissingLine
+	// DanglingFootpath means that the From or To stop of a footpath is nil or not part of the timetable.
+	DanglingFootpath
+	// NegativeWalkingTime means that the Duration of a footpath is negative.
+	NegativeWalkingTime
+	// NilStop means that a stop of the timetable is nil.
+	NilStop
+)
+
+func (p ProblemKind) String() string {
+	switch p {
+	case DuplicateStopId:
+		return "duplicate stop id"
+	case DanglingNextStop:
+		return "dangling next stop"
+	case InvalidDeparture:
+		return "invalid departure"
+	case NegativeTravelTime:
+		return "negative travel time"
+	case MissingLine:
+		return "missing line"
+	case DanglingFootpath:
+		return "dangling footpath"
+	case NegativeWalkingTime:
+		return "negative walking time"
+	case NilStop:
+		return "nil stop"
+	}
+	return fmt.Sprintf("ProblemKind(%d)", int(p))
+}
+
+// Problem describes a single problem found when validating a timetable. Stop is the stop
+// the problem was found at, and Event is the index of the affected event within Stop.Events. If the
+// problem does not concern an event, then Event is -1. If the problem concerns a footpath, then Footpath
+// is the index of the footpath in the order the footpaths were registered, otherwise it is -1.
+type Problem struct {
+	Kind     ProblemKind
+	Stop     *Stop
+	Event    int
+	Footpath int
+	Message  string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Kind, p.Message)
+}
+
+// ValidationError is returned by NewTimetableStrict and contains all problems of the timetable.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (v *ValidationError) Error() string {
+	messages := make([]string, 0, len(v.Problems))
+	for _, problem := range v.Problems {
+		messages = append(messages, problem.String())
+	}
+	return fmt.Sprintf("the timetable contains %d problem(s): %s", len(v.Problems), strings.Join(messages, "; "))
+}
+
+// Validate checks the passed stops and options for problems which would make queries fail or return wrong
+// results. All found problems are returned, if there are none, then the result is empty.
+func Validate(stops []*Stop, options ...Option) []Problem {
+	_, problems := newValidatedTimetable(stops, options...)
+	return problems
+}
+
+// NewTimetableStrict creates a new timetable like NewTimetable, but validates the stops
+// and options first. If there are problems, then a *ValidationError containing all problems is returned.
+func NewTimetableStrict(stops []*Stop, options ...Option) (Timetable, error) {
+	timetable, problems := newValidatedTimetable(stops, options...)
+	if len(problems) != 0 {
+		return Timetable{}, &ValidationError{Problems: problems}
+	}
+	return timetable, nil
+}
+
+// newValidatedTimetable reports nil stops before it creates the timetable of the remaining stops and validates it.
+func newValidatedTimetable(stops []*Stop, options ...Option) (Timetable, []Problem) {
+	problems := make([]Problem, 0, 0)
+	valid := make([]*Stop, 0, len(stops))
+	for i, stop := range stops {
+		if stop == nil {
+			message := fmt.Sprintf("stop %d is nil", i)
+			problems = append(problems, Problem{Kind: NilStop, Event: -1, Footpath: -1, Message: message})
+			continue
+		}
+		valid = append(valid, stop)
+	}
+	timetable := NewTimetable(valid, options...)
+	return timetable, append(problems, timetable.validate(valid)...)
+}
+
+func (t *Timetable) validate(stops []*Stop) []Problem {
+	problems := make([]Problem, 0, 0)
+	seen := make(map[string]bool)
+	for _, stop := range stops {
+		if seen[stop.Id] {
+			message := fmt.Sprintf("the id \"%s\" is used by more than one stop", stop.Id)
+			problems = append(problems, Problem{Kind: DuplicateStopId, Stop: stop, Event: -1, Footpath: -1, Message: message})
+		}
+		seen[stop.Id] = true
+		for i, event := range stop.Events {
+			problems = append(problems, t.validateEvent(stop, i, event)...)
+		}
+	}
+	for i, footpath := range t.footpaths {
+		problem := Problem{Stop: footpath.From, Event: -1, Footpath: i}
+		if !t.contains(footpath.From) || !t.contains(footpath.To) {
+			problem.Kind = DanglingFootpath
+			problem.Message = fmt.Sprintf("footpath %d connects a stop that is not part of the timetable", i)
+			problems = append(problems, problem)
+		}
+		if footpath.Duration < 0 {
+			problem.Kind = NegativeWalkingTime
+			problem.Message = fmt.Sprintf("footpath %d has the negative duration %v", i, footpath.Duration)
+			problems = append(problems, problem)
+		}
+	}
+	return problems
+}
+
+func (t *Timetable) validateEvent(stop *Stop, index int, event Event) []Problem {
+	problems := make([]Problem, 0, 0)
+	newProblem := func(kind ProblemKind, format string, args ...interface{}) {
+		prefix := fmt.Sprintf("event %d of stop \"%s\": ", index, stop.Id)
+		problem := Problem{Kind: kind, Stop: stop, Event: index, Footpath: -1, Message: prefix + fmt.Sprintf(format, args...)}
+		problems = append(problems, problem)
+	}
+	if event.NextStop == nil {
+		newProblem(DanglingNextStop, "the next stop is nil")
+	} else if !t.contains(event.NextStop) {
+		newProblem(DanglingNextStop, "the next stop \"%s\" is not part of the timetable", event.NextStop.Id)
+	}
+	if _, err := event.Departure.interpret(time.Time{}); err != nil {
+		newProblem(InvalidDeparture, "%v", err)
+	}
+	if event.TravelTime < 0 {
+		newProblem(NegativeTravelTime, "the travel time %v is negative", event.TravelTime)
+	}
+	if event.Line == nil {
+		newProblem(MissingLine, "the line is nil")
+	}
+	return problems
+}
+
+func (t *Timetable) contains(stop *Stop) bool {
+	if stop == nil {
+		return false
+	}
+	_, ok := t.stops[stop.Id]
+	return ok
+}
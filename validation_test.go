@@ -0,0 +1,87 @@
+package routing
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestNewTimetableStrict(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		network := createTestNetwork()
+		footpath := Footpath{From: network.docksAE, To: network.docksFG, Duration: 4 * time.Minute}
+		timetable, err := NewTimetableStrict(network.stops(), WithFootpaths(footpath))
+		require.NoError(t, err)
+		connection, err := timetable.Query(network.northAvenue, network.schusterStreet, date("14:34"))
+		require.NoError(t, err)
+		assert.Equal(t, date("14:51"), connection.Arrival, "time is wrong")
+	})
+	t.Run("invalid", func(t *testing.T) {
+		zoo := NewStop("ZO", "Zoo")
+		mall := NewStop("MA", "Mall")
+		otherMall := NewStop("MA", "Other Mall")
+		court := NewStop("CO", "Court")
+		palace := NewStop("PA", "Palace")
+		southBound := &Line{Name: "1 SouthBound", Id: "1"}
+		zoo.Events = []Event{
+			{Departure: "8:00", Line: southBound, NextStop: mall, TravelTime: 2 * time.Minute},
+			{Departure: "8:1", Line: southBound, NextStop: palace, TravelTime: -2 * time.Minute},
+			{Departure: "8:20", NextStop: nil, TravelTime: 2 * time.Minute},
+		}
+		footpaths := WithFootpaths(
+			Footpath{From: zoo, To: court, Duration: 3 * time.Minute},
+			Footpath{From: court, To: palace, Duration: -3 * time.Minute},
+		)
+
+		timetable, err := NewTimetableStrict([]*Stop{zoo, mall, otherMall, court}, footpaths)
+		assert.Equal(t, Timetable{}, timetable, "no timetable should be returned")
+		require.IsType(t, &ValidationError{}, err, "type of error is wrong")
+		problems := err.(*ValidationError).Problems
+		expected := []Problem{
+			{Kind: InvalidDeparture, Stop: zoo, Event: 1, Footpath: -1, Message: "event 1 of stop \"ZO\": the string \"8:1\" is invalid: time does not match the required format"},
+			{Kind: DanglingNextStop, Stop: zoo, Event: 1, Footpath: -1, Message: "event 1 of stop \"ZO\": the next stop \"PA\" is not part of the timetable"},
+			{Kind: NegativeTravelTime, Stop: zoo, Event: 1, Footpath: -1, Message: "event 1 of stop \"ZO\": the travel time -2m0s is negative"},
+			{Kind: DanglingNextStop, Stop: zoo, Event: 2, Footpath: -1, Message: "event 2 of stop \"ZO\": the next stop is nil"},
+			{Kind: MissingLine, Stop: zoo, Event: 2, Footpath: -1, Message: "event 2 of stop \"ZO\": the line is nil"},
+			{Kind: DuplicateStopId, Stop: otherMall, Event: -1, Footpath: -1, Message: "the id \"MA\" is used by more than one stop"},
+			{Kind: DanglingFootpath, Stop: court, Event: -1, Footpath: 1, Message: "footpath 1 connects a stop that is not part of the timetable"},
+			{Kind: NegativeWalkingTime, Stop: court, Event: -1, Footpath: 1, Message: "footpath 1 has the negative duration -3m0s"},
+		}
+		assert.ElementsMatch(t, expected, problems, "problems are wrong")
+		assert.Contains(t, err.Error(), "the timetable contains 8 problem(s): ", "error message is wrong")
+		assert.Contains(t, err.Error(), "missing line: event 2 of stop \"ZO\": the line is nil", "error message is wrong")
+
+		assert.ElementsMatch(t, expected, Validate([]*Stop{zoo, mall, otherMall, court}, footpaths), "Validate should return the same problems")
+	})
+	t.Run("nil stop", func(t *testing.T) {
+		zoo := NewStop("ZO", "Zoo")
+		mall := NewStop("MA", "Mall")
+		zoo.Events = []Event{{Departure: "8:00", NextStop: mall, TravelTime: 2 * time.Minute}}
+
+		_, err := NewTimetableStrict([]*Stop{zoo, nil, mall})
+		require.IsType(t, &ValidationError{}, err, "type of error is wrong")
+		expected := []Problem{
+			{Kind: NilStop, Event: -1, Footpath: -1, Message: "stop 1 is nil"},
+			{Kind: MissingLine, Stop: zoo, Event: 0, Footpath: -1, Message: "event 0 of stop \"ZO\": the line is nil"},
+		}
+		assert.Equal(t, expected, err.(*ValidationError).Problems, "problems are wrong")
+		assert.Equal(t, expected, Validate([]*Stop{zoo, nil, mall}), "Validate should return the same problems")
+	})
+}
+
+func TestNewTimetable_danglingReferences(t *testing.T) {
+	zoo := NewStop("ZO", "Zoo")
+	mall := NewStop("MA", "Mall")
+	palace := NewStop("PA", "Palace")
+	southBound := &Line{Name: "1 SouthBound", Id: "1"}
+	zoo.Events = []Event{
+		{Departure: "8:00", Line: southBound, NextStop: palace, TravelTime: 2 * time.Minute},
+		{Departure: "8:05", Line: southBound, NextStop: nil, TravelTime: 2 * time.Minute},
+		{Departure: "8:10", Line: southBound, NextStop: mall, TravelTime: 2 * time.Minute},
+	}
+	timetable := NewTimetable([]*Stop{zoo, mall}, WithFootpaths(Footpath{From: palace, To: mall, Duration: time.Minute}))
+	connection, err := timetable.Query(zoo, mall, date("7:55"))
+	require.NoError(t, err)
+	assert.Equal(t, date("8:12"), connection.Arrival, "dangling events should be ignored")
+}